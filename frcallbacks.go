@@ -0,0 +1,250 @@
+package frodolibs
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CallbackValue is one entry of the output or input array of an AM callback.
+type CallbackValue struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// Callback is one AM authentication callback, handlers answer it by setting its input.
+type Callback struct {
+	Type   string          `json:"type"`
+	Output []CallbackValue `json:"output,omitempty"`
+	Input  []CallbackValue `json:"input,omitempty"`
+	Id     json.RawMessage `json:"_id,omitempty"`
+}
+
+// AuthStep is a page of callbacks returned by the authenticate endpoint, it is posted back
+// with the inputs filled in.
+type AuthStep struct {
+	AuthId      string     `json:"authId"`
+	Template    string     `json:"template,omitempty"`
+	Stage       string     `json:"stage,omitempty"`
+	Header      string     `json:"header,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Callbacks   []Callback `json:"callbacks"`
+}
+
+func (cb *Callback) OutputValue(name string) (interface{}, bool) {
+	for _, output := range cb.Output {
+		if output.Name == name {
+			return output.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (cb *Callback) Prompt() string {
+	prompt, _ := cb.OutputValue("prompt")
+	promptString, _ := prompt.(string)
+	return promptString
+}
+
+// SetInput sets the first input of the callback, which is the answer for all common
+// callback types.
+func (cb *Callback) SetInput(value interface{}) error {
+	if len(cb.Input) == 0 {
+		return errors.New(fmt.Sprintf("ERROR: %s has no input", cb.Type))
+	}
+	cb.Input[0].Value = value
+	return nil
+}
+
+// CallbackHandler answers the callbacks it knows. HandleCallback returns false for
+// callbacks it leaves to the next handler.
+type CallbackHandler interface {
+	HandleCallback(cb *Callback) (bool, error)
+}
+
+// CallbackHandlerFunc lets a function be used as a CallbackHandler.
+type CallbackHandlerFunc func(cb *Callback) (bool, error)
+
+func (f CallbackHandlerFunc) HandleCallback(cb *Callback) (bool, error) {
+	return f(cb)
+}
+
+// handleCallbacks runs every callback that takes input through the handlers, the first
+// handler to accept a callback answers it. It returns the types of the callbacks no
+// handler accepted, posting those back unchanged would only get the same page again.
+func handleCallbacks(step *AuthStep, handlers []CallbackHandler) ([]string, error) {
+	var unanswered []string
+	for i := range step.Callbacks {
+		cb := &step.Callbacks[i]
+		if len(cb.Input) == 0 {
+			continue
+		}
+		handled := false
+		for _, handler := range handlers {
+			var err error
+			handled, err = handler.HandleCallback(cb)
+			if err != nil {
+				return unanswered, err
+			}
+			if handled {
+				break
+			}
+		}
+		if !handled {
+			unanswered = append(unanswered, cb.Type)
+		}
+	}
+	return unanswered, nil
+}
+
+// SkipMFAHandler takes the "skip" option that Identity Cloud offers admins who haven't
+// registered a second factor, like CheckAndSkip2FA.
+type SkipMFAHandler struct{}
+
+func (h SkipMFAHandler) HandleCallback(cb *Callback) (bool, error) {
+	if cb.Type != "HiddenValueCallback" {
+		return false, nil
+	}
+	value, _ := cb.Input[0].Value.(string)
+	if !strings.Contains(value, "skip") {
+		return false, nil
+	}
+	return true, cb.SetInput("Skip")
+}
+
+// CredentialsHandler answers the username and password prompts of a login page.
+type CredentialsHandler struct {
+	Username string
+	Password string
+}
+
+func (h CredentialsHandler) HandleCallback(cb *Callback) (bool, error) {
+	switch cb.Type {
+	case "NameCallback", "ValidatedCreateUsernameCallback":
+		return true, cb.SetInput(h.Username)
+	case "PasswordCallback", "ValidatedCreatePasswordCallback":
+		return true, cb.SetInput(h.Password)
+	}
+	return false, nil
+}
+
+var defaultOTPPrompt = regexp.MustCompile(`(?i)(one[ -]?time|otp|verification code|passcode|token)`)
+
+// TOTPHandler computes RFC 6238 codes from the base32 shared secret of an authenticator
+// app registration and enters them where a prompt asks for one. Digits and Period
+// default to 6 and 30 seconds, PromptPattern to prompts mentioning a code or OTP.
+type TOTPHandler struct {
+	Secret        string
+	Digits        int
+	Period        time.Duration
+	PromptPattern *regexp.Regexp
+	// for tests, defaults to time.Now
+	Now func() time.Time
+}
+
+func (h TOTPHandler) HandleCallback(cb *Callback) (bool, error) {
+	switch cb.Type {
+	case "NameCallback", "PasswordCallback", "TextInputCallback":
+	default:
+		return false, nil
+	}
+	pattern := h.PromptPattern
+	if pattern == nil {
+		pattern = defaultOTPPrompt
+	}
+	if !pattern.MatchString(cb.Prompt()) {
+		return false, nil
+	}
+	now := time.Now
+	if h.Now != nil {
+		now = h.Now
+	}
+	code, err := TOTP(h.Secret, now(), h.Digits, h.Period)
+	if err != nil {
+		return false, err
+	}
+	return true, cb.SetInput(code)
+}
+
+// TOTP returns the time based one time password for secret at t, digits and period
+// default to 6 and 30 seconds when zero.
+func TOTP(secret string, t time.Time, digits int, period time.Duration) (string, error) {
+	if digits == 0 {
+		digits = 6
+	}
+	if period == 0 {
+		period = 30 * time.Second
+	}
+	if digits < 1 || digits > 9 {
+		return "", errors.New(fmt.Sprintf("ERROR: totp digits must be between 1 and 9, got %d", digits))
+	}
+	if period < time.Second {
+		return "", errors.New(fmt.Sprintf("ERROR: totp period must be at least 1s, got %s", period))
+	}
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("ERROR: fail to decode totp secret, %s", err.Error()))
+	}
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(t.Unix()/int64(period/time.Second)))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulo), nil
+}
+
+// ChoiceHandler picks the option of a ChoiceCallback whose text equals Choice, or the
+// default choice when Choice is empty.
+type ChoiceHandler struct {
+	Choice string
+}
+
+func (h ChoiceHandler) HandleCallback(cb *Callback) (bool, error) {
+	if cb.Type != "ChoiceCallback" {
+		return false, nil
+	}
+	if h.Choice == "" {
+		defaultChoice, _ := cb.OutputValue("defaultChoice")
+		return true, cb.SetInput(defaultChoice)
+	}
+	choices, _ := cb.OutputValue("choices")
+	choiceList, _ := choices.([]interface{})
+	for index, choice := range choiceList {
+		if choice == h.Choice {
+			return true, cb.SetInput(index)
+		}
+	}
+	return false, errors.New(fmt.Sprintf("ERROR: choice %s not offered by %q, options are %v", h.Choice, cb.Prompt(), choiceList))
+}
+
+// PromptHandler hands every callback that takes input to Prompt, for example to ask the
+// user at a terminal. Prompt returns the value to enter.
+type PromptHandler struct {
+	Prompt func(cb *Callback) (interface{}, error)
+}
+
+func (h PromptHandler) HandleCallback(cb *Callback) (bool, error) {
+	if h.Prompt == nil {
+		return false, errors.New("ERROR: PromptHandler has no Prompt function")
+	}
+	value, err := h.Prompt(cb)
+	if err != nil {
+		return false, err
+	}
+	return true, cb.SetInput(value)
+}
@@ -0,0 +1,118 @@
+package frodolibs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, the SHA1 secret is "12345678901234567890"
+const rfc6238Secret string = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTP(t *testing.T) {
+	tests := []struct {
+		unix   int64
+		digits int
+		period time.Duration
+		want   string
+	}{
+		{59, 8, 30 * time.Second, "94287082"},
+		{1111111109, 8, 30 * time.Second, "07081804"},
+		{1111111111, 8, 0, "14050471"},
+		{1234567890, 8, 0, "89005924"},
+		{59, 0, 0, "287082"},
+	}
+	for _, test := range tests {
+		got, err := TOTP(rfc6238Secret, time.Unix(test.unix, 0), test.digits, test.period)
+		if err != nil {
+			t.Fatalf("TOTP at %d: %v", test.unix, err)
+		}
+		if got != test.want {
+			t.Errorf("TOTP at %d = %s, want %s", test.unix, got, test.want)
+		}
+	}
+}
+
+func TestTOTPInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		digits int
+		period time.Duration
+	}{
+		{"period below a second", rfc6238Secret, 6, 500 * time.Millisecond},
+		{"too many digits", rfc6238Secret, 10, 0},
+		{"secret not base32", "not-base32!", 6, 0},
+	}
+	for _, test := range tests {
+		_, err := TOTP(test.secret, time.Unix(59, 0), test.digits, test.period)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestPromptHandlerWithoutPrompt(t *testing.T) {
+	cb := &Callback{Type: "NameCallback", Input: []CallbackValue{{Name: "IDToken1", Value: ""}}}
+	_, err := PromptHandler{}.HandleCallback(cb)
+	if err == nil {
+		t.Error("expected an error for a PromptHandler without Prompt")
+	}
+}
+
+func TestLoginStopsAtUnansweredCallback(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"authId":"a","callbacks":[{"type":"PasswordCallback","output":[{"name":"prompt","value":"Enter code"}],"input":[{"name":"IDToken1","value":""}]}]}`))
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(&FRToken{tenant: srv.URL, realm: "/"})
+	_, err := c.login(context.Background(), "admin", "secret", []CallbackHandler{SkipMFAHandler{}})
+	if err == nil {
+		t.Fatal("expected login to fail")
+	}
+	if calls != 1 {
+		t.Errorf("authenticate was called %d times, want 1", calls)
+	}
+}
+
+func TestLoginAnswersCallbacks(t *testing.T) {
+	now := time.Unix(59, 0)
+	want, _ := TOTP(rfc6238Secret, now, 0, 0)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var step AuthStep
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Write([]byte(`{"authId":"a","callbacks":[{"type":"ChoiceCallback","output":[{"name":"prompt","value":"Method"},{"name":"choices","value":["email","otp"]},{"name":"defaultChoice","value":0}],"input":[{"name":"IDToken1","value":0}]}]}`))
+		case 2:
+			decodeBody(t, r, &step)
+			if step.Callbacks[0].Input[0].Value != float64(1) {
+				t.Errorf("choice = %v, want 1", step.Callbacks[0].Input[0].Value)
+			}
+			w.Write([]byte(`{"authId":"b","callbacks":[{"type":"PasswordCallback","output":[{"name":"prompt","value":"One Time Password"}],"input":[{"name":"IDToken1","value":""}]}]}`))
+		default:
+			decodeBody(t, r, &step)
+			if step.AuthId != "b" || step.Callbacks[0].Input[0].Value != want {
+				t.Errorf("got step %+v, want code %s", step, want)
+			}
+			w.Write([]byte(`{"tokenId":"session"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(&FRToken{tenant: srv.URL, realm: "/"})
+	handlers := []CallbackHandler{ChoiceHandler{Choice: "otp"}, TOTPHandler{Secret: rfc6238Secret, Now: func() time.Time { return now }}}
+	tokenId, err := c.login(context.Background(), "admin", "secret", handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenId != "session" {
+		t.Errorf("tokenId = %s, want session", tokenId)
+	}
+}
@@ -0,0 +1,228 @@
+package frodolibs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Client owns one configured HTTP client for a tenant and realm. The package level
+// functions taking an FRToken create a default Client for every call, use a Client
+// directly to share connections and settings between calls. Every call has a Context
+// variant that passes ctx on to its requests, for cancellation and deadlines.
+//
+// A Client renews the tokens of the FRToken it was created with in place. The package
+// level functions get a copy of the FRToken, so tokens they renew are lost when they
+// return and the next call has to renew them again. Long runs should use one Client.
+type Client struct {
+	frt         *FRToken
+	httpClient  *resty.Client
+	concurrency int
+	// tokenMu guards the tokens in frt, refreshMu lets one goroutine at a time renew them
+	tokenMu   sync.RWMutex
+	refreshMu sync.Mutex
+}
+
+// calls in flight at once when a Client fetches the objects of a journey
+const defaultConcurrency int = 4
+
+type ClientOption func(*Client) error
+
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		c.httpClient.SetTimeout(timeout)
+		return nil
+	}
+}
+
+func WithProxy(proxyURL string) ClientOption {
+	return func(c *Client) error {
+		_, err := url.Parse(proxyURL)
+		if err != nil {
+			return errors.New(fmt.Sprintf("ERROR: invalid proxy url, %s", err.Error()))
+		}
+		c.httpClient.SetProxy(proxyURL)
+		return nil
+	}
+}
+
+// WithCABundle trusts only the certificates in the PEM file, for tenants with a private CA.
+func WithCABundle(pemFile string) ClientOption {
+	return func(c *Client) error {
+		pem, err := os.ReadFile(pemFile)
+		if err != nil {
+			return errors.New(fmt.Sprintf("ERROR: fail to read CA bundle, %s", err.Error()))
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New(fmt.Sprintf("ERROR: no certificates found in %s", pemFile))
+		}
+		c.httpClient.SetTLSClientConfig(&tls.Config{RootCAs: pool})
+		return nil
+	}
+}
+
+// WithConcurrency limits how many calls a Client makes at once while exporting a journey.
+func WithConcurrency(concurrency int) ClientOption {
+	return func(c *Client) error {
+		if concurrency < 1 {
+			return errors.New(fmt.Sprintf("ERROR: concurrency must be at least 1, got %d", concurrency))
+		}
+		c.concurrency = concurrency
+		return nil
+	}
+}
+
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) error {
+		c.httpClient.SetHeader("User-Agent", userAgent)
+		return nil
+	}
+}
+
+func WithDebug(debug bool) ClientOption {
+	return func(c *Client) error {
+		c.httpClient.SetDebug(debug)
+		return nil
+	}
+}
+
+func NewClient(frt *FRToken, opts ...ClientOption) (*Client, error) {
+	c := &Client{frt: frt, httpClient: resty.New(), concurrency: defaultConcurrency}
+	// the session is passed explicitly on every call, don't let a jar add another one
+	c.httpClient.SetCookieJar(nil)
+	applyRetryPolicy(c.httpClient, DefaultRetryPolicy())
+	c.httpClient.OnBeforeRequest(c.refreshMiddleware)
+	for _, opt := range opts {
+		err := opt(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func defaultClient(frt *FRToken) *Client {
+	// without options NewClient can't fail
+	c, _ := NewClient(frt)
+	return c
+}
+
+func (c *Client) Token() *FRToken {
+	return c.frt
+}
+
+// amURL fills in the tenant and realm path of AM url templates, followed by args.
+func (c *Client) amURL(template string, args ...interface{}) string {
+	return fmt.Sprintf(template, append([]interface{}{c.frt.tenant, GetRealmUrl(c.frt.realm)}, args...)...)
+}
+
+func (c *Client) idmURL(template string, args ...interface{}) string {
+	return fmt.Sprintf(template, append([]interface{}{GetTenantURL(c.frt.tenant)}, args...)...)
+}
+
+func (c *Client) request() *resty.Request {
+	return c.httpClient.R()
+}
+
+// amRequest and idmRequest build requests that carry the admin tokens, their context is
+// marked so refreshMiddleware only ever touches these.
+func (c *Client) amRequest(ctx context.Context) *resty.Request {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	req := c.request().
+		SetContext(context.WithValue(ctx, adminTokensKey{}, true)).
+		SetHeader("Accept-API-Version", amApiVersion).
+		SetHeader("X-Requested-With", "XmlHttpRequest")
+	// service accounts have no AM session, AM takes their access token instead
+	if c.frt.tokenId == "" && c.frt.bearerToken != "" {
+		return req.SetHeader("Authorization", fmt.Sprintf("Bearer %s", c.frt.bearerToken))
+	}
+	return req.SetCookie(&http.Cookie{Name: c.frt.cookieName, Value: c.frt.tokenId})
+}
+
+func (c *Client) idmRequest(ctx context.Context) *resty.Request {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.request().
+		SetContext(context.WithValue(ctx, adminTokensKey{}, true)).
+		SetHeader("Authorization", fmt.Sprintf("Bearer %s", c.frt.bearerToken))
+}
+
+// redirectClient shares the transport and headers of c but follows redirects with policy,
+// so the authorize call can pick up the code without changing c.
+func (c *Client) redirectClient(policy resty.RedirectPolicy) *resty.Client {
+	hc := c.httpClient.GetClient()
+	rc := resty.NewWithClient(&http.Client{Transport: hc.Transport, Timeout: hc.Timeout})
+	rc.SetHeaders(flattenHeader(c.httpClient.Header))
+	rc.SetDebug(c.httpClient.Debug)
+	rc.SetRedirectPolicy(policy)
+	return rc
+}
+
+// forEach calls fn for 0..n-1 on up to c.concurrency goroutines. The first error cancels
+// the ctx passed to the remaining calls and is returned once all of them have stopped.
+func (c *Client) forEach(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	indexes := make(chan int)
+	workers := c.concurrency
+	if workers > n {
+		workers = n
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				err := fn(ctx, i)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	fed := 0
+feed:
+	for ; fed < n; fed++ {
+		select {
+		case indexes <- fed:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr == nil && fed < n {
+		// the caller's ctx ended before any call failed
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+func flattenHeader(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name := range header {
+		headers[name] = header.Get(name)
+	}
+	return headers
+}
@@ -0,0 +1,560 @@
+package frodolibs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// fields that change on every save and say nothing about the journey itself
+var volatileFields = map[string]bool{
+	"_rev": true,
+}
+
+// tree node layout is not interesting when reviewing a change
+var treeNodeLayoutFields = map[string]bool{
+	"connections": true,
+	"x":           true,
+	"y":           true,
+}
+
+type PropertyChange struct {
+	Property string
+	Old      interface{}
+	New      interface{}
+}
+
+type ConnectionChange struct {
+	Outcome string
+	// empty when the outcome was not connected before
+	Old string
+	// empty when the outcome is no longer connected
+	New string
+}
+
+type NodeDiff struct {
+	Id          string
+	NodeType    string
+	DisplayName string
+	// true for nodes inside a page node
+	Inner             bool
+	ConnectionChanges []ConnectionChange
+	PropertyChanges   []PropertyChange
+}
+
+type ScriptDiff struct {
+	Id              string
+	Name            string
+	PropertyChanges []PropertyChange
+	// unified diff of the decoded script source, empty if the source is unchanged
+	SourceDiff string
+}
+
+type EmailTemplateDiff struct {
+	Id              string
+	PropertyChanges []PropertyChange
+}
+
+type JourneyDiff struct {
+	TreeChanges           []PropertyChange
+	NodesAdded            []NodeDiff
+	NodesRemoved          []NodeDiff
+	NodesChanged          []NodeDiff
+	ScriptsAdded          []ScriptDiff
+	ScriptsRemoved        []ScriptDiff
+	ScriptsChanged        []ScriptDiff
+	EmailTemplatesAdded   []EmailTemplateDiff
+	EmailTemplatesRemoved []EmailTemplateDiff
+	EmailTemplatesChanged []EmailTemplateDiff
+}
+
+func (d JourneyDiff) IsEmpty() bool {
+	return len(d.TreeChanges) == 0 &&
+		len(d.NodesAdded) == 0 && len(d.NodesRemoved) == 0 && len(d.NodesChanged) == 0 &&
+		len(d.ScriptsAdded) == 0 && len(d.ScriptsRemoved) == 0 && len(d.ScriptsChanged) == 0 &&
+		len(d.EmailTemplatesAdded) == 0 && len(d.EmailTemplatesRemoved) == 0 && len(d.EmailTemplatesChanged) == 0
+}
+
+func asMap(object interface{}) map[string]interface{} {
+	objectMap, ok := object.(map[string]interface{})
+	if !ok {
+		return make(map[string]interface{})
+	}
+	return objectMap
+}
+
+func sortedKeys(objectMap map[string]interface{}) []string {
+	keys := make([]string, 0, len(objectMap))
+	for key := range objectMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func diffProperties(a map[string]interface{}, b map[string]interface{}, skip map[string]bool) []PropertyChange {
+	changes := make([]PropertyChange, 0)
+	keys := make(map[string]bool)
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		if !volatileFields[key] && !skip[key] {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		if !reflect.DeepEqual(a[key], b[key]) {
+			changes = append(changes, PropertyChange{Property: key, Old: a[key], New: b[key]})
+		}
+	}
+	return changes
+}
+
+func diffConnections(a map[string]interface{}, b map[string]interface{}) []ConnectionChange {
+	changes := make([]ConnectionChange, 0)
+	aConnections := asMap(a["connections"])
+	bConnections := asMap(b["connections"])
+	outcomes := make(map[string]bool)
+	for outcome := range aConnections {
+		outcomes[outcome] = true
+	}
+	for outcome := range bConnections {
+		outcomes[outcome] = true
+	}
+	sorted := make([]string, 0, len(outcomes))
+	for outcome := range outcomes {
+		sorted = append(sorted, outcome)
+	}
+	sort.Strings(sorted)
+	for _, outcome := range sorted {
+		oldTarget, _ := aConnections[outcome].(string)
+		newTarget, _ := bConnections[outcome].(string)
+		if oldTarget != newTarget {
+			changes = append(changes, ConnectionChange{Outcome: outcome, Old: oldTarget, New: newTarget})
+		}
+	}
+	return changes
+}
+
+func journeyNodes(journeyMap map[string]interface{}) (map[string]interface{}, map[string]bool) {
+	allNodes := make(map[string]interface{})
+	innerNodes := make(map[string]bool)
+	for nodeId, node := range asMap(journeyMap["nodes"]) {
+		allNodes[nodeId] = node
+	}
+	for nodeId, node := range asMap(journeyMap["innernodes"]) {
+		allNodes[nodeId] = node
+		innerNodes[nodeId] = true
+	}
+	return allNodes, innerNodes
+}
+
+func newNodeDiff(nodeId string, node map[string]interface{}, treeNode map[string]interface{}, inner bool) NodeDiff {
+	nodeDiff := NodeDiff{Id: nodeId, Inner: inner}
+	nodeDiff.NodeType, _ = getNodeType(node)
+	if nodeDiff.NodeType == "" {
+		nodeDiff.NodeType, _ = treeNode["nodeType"].(string)
+	}
+	nodeDiff.DisplayName, _ = treeNode["displayName"].(string)
+	return nodeDiff
+}
+
+func DecodeScript(scriptMap map[string]interface{}) string {
+	encoded, _ := scriptMap["script"].(string)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		// not base64, show it as is
+		return encoded
+	}
+	return string(decoded)
+}
+
+func DiffJourneys(a map[string]interface{}, b map[string]interface{}) JourneyDiff {
+	var diff JourneyDiff
+
+	aTree := asMap(a["tree"])
+	bTree := asMap(b["tree"])
+	diff.TreeChanges = diffProperties(aTree, bTree, map[string]bool{"nodes": true, "staticNodes": true})
+
+	aTreeNodes := asMap(aTree["nodes"])
+	bTreeNodes := asMap(bTree["nodes"])
+	aNodes, aInner := journeyNodes(a)
+	bNodes, bInner := journeyNodes(b)
+	for nodeId := range aTreeNodes {
+		if _, exists := aNodes[nodeId]; !exists {
+			aNodes[nodeId] = nil
+		}
+	}
+	for nodeId := range bTreeNodes {
+		if _, exists := bNodes[nodeId]; !exists {
+			bNodes[nodeId] = nil
+		}
+	}
+
+	for _, nodeId := range sortedKeys(aNodes) {
+		aNode := asMap(aNodes[nodeId])
+		aTreeNode := asMap(aTreeNodes[nodeId])
+		if _, exists := bNodes[nodeId]; !exists {
+			diff.NodesRemoved = append(diff.NodesRemoved, newNodeDiff(nodeId, aNode, aTreeNode, aInner[nodeId]))
+			continue
+		}
+		bNode := asMap(bNodes[nodeId])
+		bTreeNode := asMap(bTreeNodes[nodeId])
+		nodeDiff := newNodeDiff(nodeId, bNode, bTreeNode, bInner[nodeId])
+		nodeDiff.ConnectionChanges = diffConnections(aTreeNode, bTreeNode)
+		nodeDiff.PropertyChanges = diffProperties(aNode, bNode, nil)
+		for _, change := range diffProperties(aTreeNode, bTreeNode, treeNodeLayoutFields) {
+			// nodeType is already part of _type
+			if change.Property != "nodeType" {
+				change.Property = "tree." + change.Property
+				nodeDiff.PropertyChanges = append(nodeDiff.PropertyChanges, change)
+			}
+		}
+		if len(nodeDiff.ConnectionChanges) > 0 || len(nodeDiff.PropertyChanges) > 0 {
+			diff.NodesChanged = append(diff.NodesChanged, nodeDiff)
+		}
+	}
+	for _, nodeId := range sortedKeys(bNodes) {
+		if _, exists := aNodes[nodeId]; !exists {
+			diff.NodesAdded = append(diff.NodesAdded, newNodeDiff(nodeId, asMap(bNodes[nodeId]), asMap(bTreeNodes[nodeId]), bInner[nodeId]))
+		}
+	}
+
+	aScripts := asMap(a["scripts"])
+	bScripts := asMap(b["scripts"])
+	for _, scriptId := range sortedKeys(aScripts) {
+		aScript := asMap(aScripts[scriptId])
+		name, _ := aScript["name"].(string)
+		if _, exists := bScripts[scriptId]; !exists {
+			diff.ScriptsRemoved = append(diff.ScriptsRemoved, ScriptDiff{Id: scriptId, Name: name})
+			continue
+		}
+		bScript := asMap(bScripts[scriptId])
+		if bName, ok := bScript["name"].(string); ok {
+			name = bName
+		}
+		scriptDiff := ScriptDiff{Id: scriptId, Name: name}
+		scriptDiff.PropertyChanges = diffProperties(aScript, bScript, map[string]bool{"script": true})
+		scriptDiff.SourceDiff = UnifiedDiff("a/"+name, "b/"+name, DecodeScript(aScript), DecodeScript(bScript))
+		if len(scriptDiff.PropertyChanges) > 0 || scriptDiff.SourceDiff != "" {
+			diff.ScriptsChanged = append(diff.ScriptsChanged, scriptDiff)
+		}
+	}
+	for _, scriptId := range sortedKeys(bScripts) {
+		if _, exists := aScripts[scriptId]; !exists {
+			name, _ := asMap(bScripts[scriptId])["name"].(string)
+			diff.ScriptsAdded = append(diff.ScriptsAdded, ScriptDiff{Id: scriptId, Name: name})
+		}
+	}
+
+	aTemplates := asMap(a["emailTemplates"])
+	bTemplates := asMap(b["emailTemplates"])
+	for _, templateId := range sortedKeys(aTemplates) {
+		if _, exists := bTemplates[templateId]; !exists {
+			diff.EmailTemplatesRemoved = append(diff.EmailTemplatesRemoved, EmailTemplateDiff{Id: templateId})
+			continue
+		}
+		changes := diffProperties(asMap(aTemplates[templateId]), asMap(bTemplates[templateId]), nil)
+		if len(changes) > 0 {
+			diff.EmailTemplatesChanged = append(diff.EmailTemplatesChanged, EmailTemplateDiff{Id: templateId, PropertyChanges: changes})
+		}
+	}
+	for _, templateId := range sortedKeys(bTemplates) {
+		if _, exists := aTemplates[templateId]; !exists {
+			diff.EmailTemplatesAdded = append(diff.EmailTemplatesAdded, EmailTemplateDiff{Id: templateId})
+		}
+	}
+	return diff
+}
+
+func formatValue(value interface{}) string {
+	if value == nil {
+		return "(none)"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+func formatTarget(target string) string {
+	switch target {
+	case "":
+		return "(none)"
+	case SuccessNodeId:
+		return "success"
+	case FailureNodeId:
+		return "failure"
+	}
+	return target
+}
+
+func writePropertyChanges(sb *strings.Builder, changes []PropertyChange) {
+	for _, change := range changes {
+		sb.WriteString(fmt.Sprintf("    %s: %s -> %s\n", change.Property, formatValue(change.Old), formatValue(change.New)))
+	}
+}
+
+func describeNode(nodeDiff NodeDiff) string {
+	description := fmt.Sprintf("%s (%s", nodeDiff.Id, nodeDiff.NodeType)
+	if nodeDiff.DisplayName != "" {
+		description += fmt.Sprintf(", %q", nodeDiff.DisplayName)
+	}
+	if nodeDiff.Inner {
+		description += ", in page"
+	}
+	return description + ")"
+}
+
+// String renders the differences as text, with script changes as unified diffs.
+func (d JourneyDiff) String() string {
+	var sb strings.Builder
+	if len(d.TreeChanges) > 0 {
+		sb.WriteString("~ tree\n")
+		writePropertyChanges(&sb, d.TreeChanges)
+	}
+	for _, nodeDiff := range d.NodesAdded {
+		sb.WriteString(fmt.Sprintf("+ node %s\n", describeNode(nodeDiff)))
+	}
+	for _, nodeDiff := range d.NodesRemoved {
+		sb.WriteString(fmt.Sprintf("- node %s\n", describeNode(nodeDiff)))
+	}
+	for _, nodeDiff := range d.NodesChanged {
+		sb.WriteString(fmt.Sprintf("~ node %s\n", describeNode(nodeDiff)))
+		for _, change := range nodeDiff.ConnectionChanges {
+			sb.WriteString(fmt.Sprintf("    connection %s: %s -> %s\n", change.Outcome, formatTarget(change.Old), formatTarget(change.New)))
+		}
+		writePropertyChanges(&sb, nodeDiff.PropertyChanges)
+	}
+	for _, scriptDiff := range d.ScriptsAdded {
+		sb.WriteString(fmt.Sprintf("+ script %s (%s)\n", scriptDiff.Name, scriptDiff.Id))
+	}
+	for _, scriptDiff := range d.ScriptsRemoved {
+		sb.WriteString(fmt.Sprintf("- script %s (%s)\n", scriptDiff.Name, scriptDiff.Id))
+	}
+	for _, scriptDiff := range d.ScriptsChanged {
+		sb.WriteString(fmt.Sprintf("~ script %s (%s)\n", scriptDiff.Name, scriptDiff.Id))
+		writePropertyChanges(&sb, scriptDiff.PropertyChanges)
+		sb.WriteString(scriptDiff.SourceDiff)
+	}
+	for _, templateDiff := range d.EmailTemplatesAdded {
+		sb.WriteString(fmt.Sprintf("+ email template %s\n", templateDiff.Id))
+	}
+	for _, templateDiff := range d.EmailTemplatesRemoved {
+		sb.WriteString(fmt.Sprintf("- email template %s\n", templateDiff.Id))
+	}
+	for _, templateDiff := range d.EmailTemplatesChanged {
+		sb.WriteString(fmt.Sprintf("~ email template %s\n", templateDiff.Id))
+		writePropertyChanges(&sb, templateDiff.PropertyChanges)
+	}
+	return sb.String()
+}
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+func diffLines(a []string, b []string) []diffLine {
+	// longest common subsequence table, scripts are small enough for this
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	lines := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		} else {
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+	return lines
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// UnifiedDiff returns the unified diff of two texts with three lines of context,
+// or an empty string when they are equal.
+func UnifiedDiff(aName string, bName string, a string, b string) string {
+	if a == b {
+		return ""
+	}
+	const context = 3
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", aName, bName))
+	for start := 0; start < len(lines); {
+		// find the next change
+		for start < len(lines) && lines[start].op == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+		hunkStart := start - context
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		// extend the hunk while changes are close enough to share context
+		hunkEnd := start
+		for index := start; index < len(lines); index++ {
+			if lines[index].op != ' ' {
+				hunkEnd = index + 1
+			} else if index-hunkEnd >= 2*context {
+				break
+			}
+		}
+		hunkEnd += context
+		if hunkEnd > len(lines) {
+			hunkEnd = len(lines)
+		}
+
+		aLine, bLine := 1, 1
+		for _, line := range lines[:hunkStart] {
+			if line.op != '+' {
+				aLine++
+			}
+			if line.op != '-' {
+				bLine++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.op != '+' {
+				aCount++
+			}
+			if line.op != '-' {
+				bCount++
+			}
+		}
+		if aCount == 0 {
+			aLine--
+		}
+		if bCount == 0 {
+			bLine--
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount))
+		for _, line := range lines[hunkStart:hunkEnd] {
+			sb.WriteString(fmt.Sprintf("%c%s\n", line.op, line.text))
+		}
+		start = hunkEnd
+	}
+	return sb.String()
+}
+
+type DriftReport struct {
+	Journey string
+	Drifted bool
+	// changes made in the tenant relative to the export file
+	Diff JourneyDiff
+}
+
+func stripVolatileFields(object interface{}) interface{} {
+	switch value := object.(type) {
+	case map[string]interface{}:
+		stripped := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			if !volatileFields[key] {
+				stripped[key] = stripVolatileFields(field)
+			}
+		}
+		return stripped
+	case []interface{}:
+		stripped := make([]interface{}, len(value))
+		for index := range value {
+			stripped[index] = stripVolatileFields(value[index])
+		}
+		return stripped
+	}
+	return object
+}
+
+// StripVolatileFields returns a copy of journeyMap without _rev fields and origin, which
+// differ between tenants and saves without the journey having changed.
+func StripVolatileFields(journeyMap map[string]interface{}) map[string]interface{} {
+	stripped := stripVolatileFields(journeyMap).(map[string]interface{})
+	delete(stripped, "origin")
+	return stripped
+}
+
+func (c *Client) CheckJourneyDrift(exportFile string) (DriftReport, error) {
+	return c.CheckJourneyDriftContext(context.Background(), exportFile)
+}
+
+func (c *Client) CheckJourneyDriftContext(ctx context.Context, exportFile string) (DriftReport, error) {
+	var report DriftReport
+	data, err := os.ReadFile(exportFile)
+	if err != nil {
+		return report, errors.New(fmt.Sprintf("ERROR: fail to read export file, %s", err.Error()))
+	}
+	fileMap := make(map[string](interface{}))
+	err = json.Unmarshal(data, &fileMap)
+	if err != nil {
+		return report, errors.New(fmt.Sprintf("ERROR: fail to unmarshal export file json, %s", err.Error()))
+	}
+	treeName, ok := asMap(fileMap["tree"])["_id"].(string)
+	if !ok {
+		return report, errors.New(fmt.Sprintf("ERROR: %s is not a journey export", exportFile))
+	}
+	report.Journey = treeName
+
+	liveMap, err := c.GetJourneyDataContext(ctx, treeName)
+	if err != nil {
+		return report, err
+	}
+	report.Diff = DiffJourneys(StripVolatileFields(fileMap), StripVolatileFields(liveMap))
+	report.Drifted = !report.Diff.IsEmpty()
+	return report, nil
+}
+
+func CheckJourneyDrift(frt FRToken, exportFile string) (DriftReport, error) {
+	return defaultClient(&frt).CheckJourneyDrift(exportFile)
+}
+
+func CheckJourneyDriftContext(ctx context.Context, frt FRToken, exportFile string) (DriftReport, error) {
+	return defaultClient(&frt).CheckJourneyDriftContext(ctx, exportFile)
+}
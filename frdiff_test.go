@@ -0,0 +1,199 @@
+package frodolibs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns the lines 1..n, with the lines in replace swapped for their value.
+func numberedLines(n int, replace map[int]string) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		if text, exists := replace[i]; exists {
+			sb.WriteString(text + "\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("%d\n", i))
+		}
+	}
+	return sb.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	base := numberedLines(20, nil)
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{"equal", base, base, ""},
+		{"one change", base, numberedLines(20, map[int]string{10: "ten"}), `--- a
++++ b
+@@ -7,7 +7,7 @@
+ 7
+ 8
+ 9
+-10
++ten
+ 11
+ 12
+ 13
+`},
+		{"changes far apart make two hunks", base, numberedLines(20, map[int]string{2: "two", 15: "fifteen"}), `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -12,7 +12,7 @@
+ 12
+ 13
+ 14
+-15
++fifteen
+ 16
+ 17
+ 18
+`},
+		{"changes sharing context merge", base, numberedLines(20, map[int]string{3: "three", 10: "ten"}), `--- a
++++ b
+@@ -1,13 +1,13 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+ 7
+ 8
+ 9
+-10
++ten
+ 11
+ 12
+ 13
+`},
+		{"seven lines apart stay separate", base, numberedLines(20, map[int]string{3: "three", 11: "eleven"}), `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -8,7 +8,7 @@
+ 8
+ 9
+ 10
+-11
++eleven
+ 12
+ 13
+ 14
+`},
+		{"added to empty", "", "x\ny\n", `--- a
++++ b
+@@ -0,0 +1,2 @@
++x
++y
+`},
+		{"everything removed", "x\ny\n", "", `--- a
++++ b
+@@ -1,2 +0,0 @@
+-x
+-y
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := UnifiedDiff("a", "b", test.a, test.b)
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func diffFixture(treeNodes string, nodes string, scriptSource string) string {
+	return `{
+		"origin": "x",
+		"tree": {"_id": "Login", "_rev": "1", "entryNodeId": "a", "nodes": {` + treeNodes + `}},
+		"nodes": {` + nodes + `},
+		"scripts": {"s1": {"_id": "s1", "name": "check", "script": "` + base64.StdEncoding.EncodeToString([]byte(scriptSource)) + `"}},
+		"emailTemplates": {}
+	}`
+}
+
+func TestDiffJourneys(t *testing.T) {
+	const treeNodeA = `"a": {"nodeType": "ScriptedDecisionNode", "displayName": "Check", "connections": {"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0"}, "x": 1}`
+	const nodeA = `"a": {"_id": "a", "_rev": "1", "_type": {"_id": "ScriptedDecisionNode"}, "script": "s1", "outcomes": ["true"]}`
+	base := diffFixture(treeNodeA, nodeA, "outcome = \"true\";\n")
+	tests := []struct {
+		name  string
+		b     string
+		check func(t *testing.T, diff JourneyDiff)
+	}{
+		{"only revisions and layout changed", diffFixture(
+			strings.Replace(treeNodeA, `"x": 1`, `"x": 500`, 1),
+			strings.Replace(nodeA, `"_rev": "1"`, `"_rev": "9"`, 1),
+			"outcome = \"true\";\n"), func(t *testing.T, diff JourneyDiff) {
+			if !diff.IsEmpty() {
+				t.Errorf("expected no differences, got\n%s", diff)
+			}
+		}},
+		{"connection and property changed", diffFixture(
+			strings.Replace(treeNodeA, `"70e691a5-1e33-4ac3-a356-e7b6d60d92e0"`, `"e301438c-0bd0-429c-ab0c-66126501069a"`, 1),
+			strings.Replace(nodeA, `["true"]`, `["true", "false"]`, 1),
+			"outcome = \"true\";\n"), func(t *testing.T, diff JourneyDiff) {
+			if len(diff.NodesChanged) != 1 {
+				t.Fatalf("NodesChanged = %v", diff.NodesChanged)
+			}
+			changed := diff.NodesChanged[0]
+			if len(changed.ConnectionChanges) != 1 || changed.ConnectionChanges[0].New != FailureNodeId {
+				t.Errorf("ConnectionChanges = %v", changed.ConnectionChanges)
+			}
+			if len(changed.PropertyChanges) != 1 || changed.PropertyChanges[0].Property != "outcomes" {
+				t.Errorf("PropertyChanges = %v", changed.PropertyChanges)
+			}
+		}},
+		{"node added", diffFixture(
+			treeNodeA+`, "b": {"nodeType": "PageNode", "connections": {}}`,
+			nodeA+`, "b": {"_id": "b", "_type": {"_id": "PageNode"}, "nodes": []}`,
+			"outcome = \"true\";\n"), func(t *testing.T, diff JourneyDiff) {
+			if len(diff.NodesAdded) != 1 || diff.NodesAdded[0].Id != "b" || diff.NodesAdded[0].NodeType != "PageNode" {
+				t.Errorf("NodesAdded = %v", diff.NodesAdded)
+			}
+			if len(diff.NodesRemoved) != 0 || len(diff.NodesChanged) != 0 {
+				t.Errorf("unexpected changes\n%s", diff)
+			}
+		}},
+		{"script source changed", diffFixture(treeNodeA, nodeA, "outcome = \"false\";\n"), func(t *testing.T, diff JourneyDiff) {
+			if len(diff.ScriptsChanged) != 1 {
+				t.Fatalf("ScriptsChanged = %v", diff.ScriptsChanged)
+			}
+			sourceDiff := diff.ScriptsChanged[0].SourceDiff
+			if !strings.Contains(sourceDiff, "-outcome = \"true\";") || !strings.Contains(sourceDiff, "+outcome = \"false\";") {
+				t.Errorf("SourceDiff =\n%s", sourceDiff)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := DiffJourneys(journeyFixture(t, base), journeyFixture(t, test.b))
+			test.check(t, diff)
+		})
+	}
+
+	diff := DiffJourneys(journeyFixture(t, base), journeyFixture(t, diffFixture("", "", "")))
+	if len(diff.NodesRemoved) != 1 || diff.NodesRemoved[0].Id != "a" {
+		t.Errorf("NodesRemoved = %v", diff.NodesRemoved)
+	}
+}
@@ -0,0 +1,122 @@
+package frodolibs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+var (
+	ErrNotFound     = errors.New("ERROR: not found")
+	ErrUnauthorized = errors.New("ERROR: unauthorized")
+	ErrForbidden    = errors.New("ERROR: forbidden")
+)
+
+// APIError is returned when AM or IDM answer with an error status. Code, Reason and
+// Message come from the JSON error body, when there is one. errors.Is matches it
+// against ErrNotFound, ErrUnauthorized and ErrForbidden by status code.
+type APIError struct {
+	StatusCode int
+	URL        string
+	Method     string
+	Code       int
+	Reason     string
+	Message    string
+
+	description string
+}
+
+func (e *APIError) Error() string {
+	if e.description != "" {
+		return e.description
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("ERROR: %s %s returned %d, %s", e.Method, e.URL, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("ERROR: %s %s returned %d", e.Method, e.URL, e.StatusCode)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == 404
+	case ErrUnauthorized:
+		return e.StatusCode == 401
+	case ErrForbidden:
+		return e.StatusCode == 403
+	}
+	return false
+}
+
+// newAPIError describes the failed call in resp, description becomes the error message.
+func newAPIError(resp *resty.Response, description string) *APIError {
+	apiErr := &APIError{
+		StatusCode:  resp.StatusCode(),
+		description: description,
+	}
+	if resp.Request != nil {
+		apiErr.URL = resp.Request.URL
+		apiErr.Method = resp.Request.Method
+	}
+	var body struct {
+		Code    int    `json:"code"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(resp.Body(), &body) == nil {
+		apiErr.Code = body.Code
+		apiErr.Reason = body.Reason
+		apiErr.Message = body.Message
+	}
+	return apiErr
+}
+
+// ObjectError is returned when reading a tree, node, script or email template fails.
+// StatusCode is 0 when the call got no response.
+type ObjectError struct {
+	Kind       string
+	Id         string
+	NodeType   string
+	StatusCode int
+	Err        error
+}
+
+func (e *ObjectError) Error() string {
+	object := fmt.Sprintf("%s %s", e.Kind, e.Id)
+	if e.NodeType != "" {
+		object = fmt.Sprintf("%s %s (%s)", e.Kind, e.Id, e.NodeType)
+	}
+	return fmt.Sprintf("ERROR: %s: %s", object, strings.TrimPrefix(e.Err.Error(), "ERROR: "))
+}
+
+func (e *ObjectError) Unwrap() error {
+	return e.Err
+}
+
+// ExportReport lists the objects a lenient export had to leave out.
+type ExportReport struct {
+	Failures []*ObjectError
+}
+
+func (r *ExportReport) OK() bool {
+	return len(r.Failures) == 0
+}
+
+func (r *ExportReport) add(kind string, id string, err error) {
+	var objectErr *ObjectError
+	if !errors.As(err, &objectErr) {
+		objectErr = &ObjectError{Kind: kind, Id: id, Err: err}
+	}
+	r.Failures = append(r.Failures, objectErr)
+}
+
+func newObjectError(kind string, id string, nodeType string, resp *resty.Response, err error) *ObjectError {
+	objectErr := &ObjectError{Kind: kind, Id: id, NodeType: nodeType, Err: err}
+	if resp != nil {
+		objectErr.StatusCode = resp.StatusCode()
+	}
+	return objectErr
+}
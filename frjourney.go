@@ -1,6 +1,7 @@
 package frodolibs
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -8,9 +9,8 @@ import (
 	"fmt"
 
 	// "log"
-	"net/http"
-
-	"github.com/go-resty/resty/v2"
+	"sort"
+	"strings"
 )
 
 const journeyURLTemplate string = "%s/json%s/realm-config/authentication/authenticationtrees/trees/%s"
@@ -19,6 +19,10 @@ const scriptURLTemplate string = "%s/json%s/scripts/%s"
 const emailTemplateURLTemplate string = "%s/openidm/config/emailTemplate/%s"
 const queryAllTreesURLTemplate string = "%s/json%s/realm-config/authentication/authenticationtrees/trees?_queryFilter=true"
 
+// static nodes every tree can connect to
+const SuccessNodeId string = "70e691a5-1e33-4ac3-a356-e7b6d60d92e0"
+const FailureNodeId string = "e301438c-0bd0-429c-ab0c-66126501069a"
+
 var containerNodes = map[string]bool{
 	"PageNode":       true,
 	"CustomPageNode": true,
@@ -35,104 +39,134 @@ var emailTemplateNodes = map[string]bool{
 	"EmailTemplateNode": true,
 }
 
-func GetNodeData(frt FRToken, id string, nodeType string) ([]byte, error) {
+func (c *Client) GetNodeData(id string, nodeType string) ([]byte, error) {
+	return c.GetNodeDataContext(context.Background(), id, nodeType)
+}
+
+func (c *Client) GetNodeDataContext(ctx context.Context, id string, nodeType string) ([]byte, error) {
 	var b []byte
 
 	// log.Printf("Cookie name: %s\n", cookieName)
-	client := resty.New()
-	// client.SetDebug(true)
-	jURL := fmt.Sprintf(nodeURLTemplate, frt.tenant, GetRealmUrl(frt.realm), nodeType, id)
+	jURL := c.amURL(nodeURLTemplate, nodeType, id)
 	// log.Printf("url: %s\n", jURL)
-	resp1, err1 := client.R().
-		SetHeader("Accept-API-Version", amApiVersion).
-		SetHeader("X-Requested-With", "XmlHttpRequest").
-		SetCookie(&http.Cookie{Name: frt.cookieName, Value: frt.tokenId}).
+	resp1, err1 := c.amRequest(ctx).
 		Get(jURL)
 	// log.Printf("resp1: %s\n", resp1)
 	if err1 == nil {
 		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
-			return b, errors.New(fmt.Sprintf("ERROR: get node call returned %d, possible cause: node not found", resp1.StatusCode()))
+			return b, newObjectError("node", id, nodeType, resp1, newAPIError(resp1, fmt.Sprintf("ERROR: get node call returned %d, possible cause: node not found", resp1.StatusCode())))
 		} else {
 			// exports := []byte(`{"origin":"$ORIGIN", "innernodes":{}, "nodes":{}, "scripts":{}, "emailTemplates":{}}`)
 			jsonMap := make(map[string](interface{}))
 			err := json.Unmarshal([]byte(resp1.Body()), &jsonMap)
 			if err != nil {
-				return b, errors.New(fmt.Sprintf("ERROR: fail to unmarshal json: %s", err.Error()))
+				return b, newObjectError("node", id, nodeType, resp1, errors.New(fmt.Sprintf("ERROR: fail to unmarshal json: %s", err.Error())))
 			}
 			return resp1.Body(), nil
 		}
 	} else {
-		return b, errors.New(fmt.Sprintf("ERROR: error getting node, %s\n", err1.Error()))
+		return b, newObjectError("node", id, nodeType, resp1, err1)
 	}
 }
 
-func GetTreeData(frt FRToken, name string) ([]byte, error) {
+func GetNodeData(frt FRToken, id string, nodeType string) ([]byte, error) {
+	return defaultClient(&frt).GetNodeData(id, nodeType)
+}
+
+func GetNodeDataContext(ctx context.Context, frt FRToken, id string, nodeType string) ([]byte, error) {
+	return defaultClient(&frt).GetNodeDataContext(ctx, id, nodeType)
+}
+
+func (c *Client) GetTreeData(name string) ([]byte, error) {
+	return c.GetTreeDataContext(context.Background(), name)
+}
+
+func (c *Client) GetTreeDataContext(ctx context.Context, name string) ([]byte, error) {
 	var b []byte
-	client := resty.New()
-	// client.SetDebug(true)
-	jURL := fmt.Sprintf(journeyURLTemplate, frt.tenant, GetRealmUrl(frt.realm), name)
+	jURL := c.amURL(journeyURLTemplate, name)
 	// log.Printf("url: %s\n", jURL)
 	// read tree object
-	resp, err := client.R().
-		SetHeader("Accept-API-Version", amApiVersion).
-		SetHeader("X-Requested-With", "XmlHttpRequest").
-		SetCookie(&http.Cookie{Name: frt.cookieName, Value: frt.tokenId}).
+	resp, err := c.amRequest(ctx).
 		Get(jURL)
 	// log.Printf("resp1: %s\n", resp1.Body())
 	if err == nil {
 		if resp.StatusCode() < 200 || resp.StatusCode() > 399 {
-			return b, errors.New(fmt.Sprintf("ERROR: export journey call returned %d, possible cause: tree not found", resp.StatusCode()))
+			return b, newObjectError("tree", name, "", resp, newAPIError(resp, fmt.Sprintf("ERROR: export journey call returned %d, possible cause: tree not found", resp.StatusCode())))
 		} else {
 			return resp.Body(), nil
 		}
 	} else {
-		return b, errors.New(fmt.Sprintf("ERROR: get tree data error: %s", err.Error()))
+		return b, newObjectError("tree", name, "", resp, err)
 	}
 }
 
-func GetScriptData(frt FRToken, id string) ([]byte, error) {
+func GetTreeData(frt FRToken, name string) ([]byte, error) {
+	return defaultClient(&frt).GetTreeData(name)
+}
+
+func GetTreeDataContext(ctx context.Context, frt FRToken, name string) ([]byte, error) {
+	return defaultClient(&frt).GetTreeDataContext(ctx, name)
+}
+
+func (c *Client) GetScriptData(id string) ([]byte, error) {
+	return c.GetScriptDataContext(context.Background(), id)
+}
+
+func (c *Client) GetScriptDataContext(ctx context.Context, id string) ([]byte, error) {
 	var b []byte
 
-	client := resty.New()
-	// client.SetDebug(true)
-	jURL := fmt.Sprintf(scriptURLTemplate, frt.tenant, GetRealmUrl(frt.realm), id)
+	jURL := c.amURL(scriptURLTemplate, id)
 	// log.Printf("url: %s\n", jURL)
-	resp1, err1 := client.R().
-		SetHeader("Accept-API-Version", amApiVersion).
-		SetHeader("X-Requested-With", "XmlHttpRequest").
-		SetCookie(&http.Cookie{Name: frt.cookieName, Value: frt.tokenId}).
+	resp1, err1 := c.amRequest(ctx).
 		Get(jURL)
 	// log.Printf("resp1: %s\n", resp1)
 	if err1 == nil {
 		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
-			return b, errors.New(fmt.Sprintf("ERROR: get script call returned %d, possible cause: script not found", resp1.StatusCode()))
+			return b, newObjectError("script", id, "", resp1, newAPIError(resp1, fmt.Sprintf("ERROR: get script call returned %d, possible cause: script not found", resp1.StatusCode())))
 		} else {
 			// exports := []byte(`{"origin":"$ORIGIN", "innernodes":{}, "nodes":{}, "scripts":{}, "emailTemplates":{}}`)
 			jsonMap := make(map[string](interface{}))
 			err := json.Unmarshal([]byte(resp1.Body()), &jsonMap)
 			if err != nil {
-				return b, errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err.Error()))
+				return b, newObjectError("script", id, "", resp1, errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err.Error())))
 			}
 			return resp1.Body(), nil
 		}
 	} else {
-		return b, errors.New(fmt.Sprintf("ERROR: error getting script, %s\n", err1.Error()))
+		return b, newObjectError("script", id, "", resp1, err1)
 	}
 }
 
-func GetScriptDataAsMap(frt FRToken, data []byte) (map[string](interface{}), string, error) {
+func GetScriptData(frt FRToken, id string) ([]byte, error) {
+	return defaultClient(&frt).GetScriptData(id)
+}
+
+func GetScriptDataContext(ctx context.Context, frt FRToken, id string) ([]byte, error) {
+	return defaultClient(&frt).GetScriptDataContext(ctx, id)
+}
+
+func (c *Client) GetScriptDataAsMap(data []byte) (map[string](interface{}), string, error) {
+	return c.GetScriptDataAsMapContext(context.Background(), data)
+}
+
+func (c *Client) GetScriptDataAsMapContext(ctx context.Context, data []byte) (map[string](interface{}), string, error) {
 	scriptDataMap := make(map[string](interface{}))
-	scriptedNodeMap := make(map[string](interface{}))
-	err1 := json.Unmarshal([]byte(data), &scriptedNodeMap)
+	var scriptedNode Node
+	err1 := json.Unmarshal([]byte(data), &scriptedNode)
 	if err1 != nil {
 		return scriptDataMap, "", errors.New(fmt.Sprintf("ERROR: fail to unmarshal scripted node json, %s", err1.Error()))
 	}
-	scriptId := scriptedNodeMap["script"].(string)
+	scriptId, exists := scriptedNode.StringProperty("script")
+	if !exists {
+		return scriptDataMap, "", errors.New(fmt.Sprintf("ERROR: scripted node %s has no script", scriptedNode.Id))
+	}
 	// log.Printf("script id: %s\n", scriptId)
 
 	// get the script
-	scriptData, _ := GetScriptData(frt, scriptId)
-	_ = scriptData
+	scriptData, err2 := c.GetScriptDataContext(ctx, scriptId)
+	if err2 != nil {
+		return scriptDataMap, scriptId, err2
+	}
 	// log.Printf("script data: %s\n", scriptData)
 
 	err := json.Unmarshal([]byte(scriptData), &scriptDataMap)
@@ -144,44 +178,71 @@ func GetScriptDataAsMap(frt FRToken, data []byte) (map[string](interface{}), str
 	return scriptDataMap, scriptId, nil
 }
 
-func GetEmailTemplateData(frt FRToken, id string) ([]byte, error) {
+func GetScriptDataAsMap(frt FRToken, data []byte) (map[string](interface{}), string, error) {
+	return defaultClient(&frt).GetScriptDataAsMap(data)
+}
+
+func GetScriptDataAsMapContext(ctx context.Context, frt FRToken, data []byte) (map[string](interface{}), string, error) {
+	return defaultClient(&frt).GetScriptDataAsMapContext(ctx, data)
+}
+
+func (c *Client) GetEmailTemplateData(id string) ([]byte, error) {
+	return c.GetEmailTemplateDataContext(context.Background(), id)
+}
+
+func (c *Client) GetEmailTemplateDataContext(ctx context.Context, id string) ([]byte, error) {
 	var b []byte
 
-	client := resty.New()
-	// client.SetDebug(true)
-	jURL := fmt.Sprintf(emailTemplateURLTemplate, GetTenantURL(frt.tenant), id)
+	jURL := c.idmURL(emailTemplateURLTemplate, id)
 	// log.Printf("url: %s\n", jURL)
-	resp1, err1 := client.R().
-		SetHeader("Authorization", fmt.Sprintf("Bearer %s", frt.bearerToken)).
+	resp1, err1 := c.idmRequest(ctx).
 		Get(jURL)
 	// log.Printf("resp1: %s\n", resp1)
 	if err1 == nil {
 		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
-			return b, errors.New(fmt.Sprintf("ERROR: get email template call returned %d, possible cause: script not found", resp1.StatusCode()))
+			return b, newObjectError("emailTemplate", id, "", resp1, newAPIError(resp1, fmt.Sprintf("ERROR: get email template call returned %d, possible cause: email template not found", resp1.StatusCode())))
 		} else {
 			// exports := []byte(`{"origin":"$ORIGIN", "innernodes":{}, "nodes":{}, "scripts":{}, "emailTemplates":{}}`)
 			jsonMap := make(map[string](interface{}))
 			err := json.Unmarshal([]byte(resp1.Body()), &jsonMap)
 			if err != nil {
-				return b, errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err.Error()))
+				return b, newObjectError("emailTemplate", id, "", resp1, errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err.Error())))
 			}
 			return resp1.Body(), nil
 		}
 	} else {
-		return b, errors.New(fmt.Sprintf("ERROR: error getting email template, %s\n", err1.Error()))
+		return b, newObjectError("emailTemplate", id, "", resp1, err1)
 	}
 }
 
-func GetEmailTemplateDataAsMap(frt FRToken, data []byte) (map[string](interface{}), string, error) {
+func GetEmailTemplateData(frt FRToken, id string) ([]byte, error) {
+	return defaultClient(&frt).GetEmailTemplateData(id)
+}
+
+func GetEmailTemplateDataContext(ctx context.Context, frt FRToken, id string) ([]byte, error) {
+	return defaultClient(&frt).GetEmailTemplateDataContext(ctx, id)
+}
+
+func (c *Client) GetEmailTemplateDataAsMap(data []byte) (map[string](interface{}), string, error) {
+	return c.GetEmailTemplateDataAsMapContext(context.Background(), data)
+}
+
+func (c *Client) GetEmailTemplateDataAsMapContext(ctx context.Context, data []byte) (map[string](interface{}), string, error) {
 	emailTemplateDataMap := make(map[string](interface{}))
-	emailTemplateNodeMap := make(map[string](interface{}))
-	err1 := json.Unmarshal([]byte(data), &emailTemplateNodeMap)
+	var emailTemplateNode Node
+	err1 := json.Unmarshal([]byte(data), &emailTemplateNode)
 	if err1 != nil {
 		return emailTemplateDataMap, "", errors.New(fmt.Sprintf("ERROR: fail to unmarshal email template node json, %s", err1.Error()))
 	}
-	templateId := emailTemplateNodeMap["emailTemplateName"].(string)
+	templateId, exists := emailTemplateNode.StringProperty("emailTemplateName")
+	if !exists {
+		return emailTemplateDataMap, "", errors.New(fmt.Sprintf("ERROR: email template node %s has no emailTemplateName", emailTemplateNode.Id))
+	}
 	// log.Printf("template id: %s\n", templateId)
-	templateData, _ := GetEmailTemplateData(frt, templateId)
+	templateData, err2 := c.GetEmailTemplateDataContext(ctx, templateId)
+	if err2 != nil {
+		return emailTemplateDataMap, templateId, err2
+	}
 	// log.Printf("template data: %s\n", templateData)
 	err := json.Unmarshal([]byte(templateData), &emailTemplateDataMap)
 	// log.Printf("journeyMap: %q\n", journeyMap)
@@ -192,132 +253,311 @@ func GetEmailTemplateDataAsMap(frt FRToken, data []byte) (map[string](interface{
 	return emailTemplateDataMap, templateId, nil
 }
 
+func GetEmailTemplateDataAsMap(frt FRToken, data []byte) (map[string](interface{}), string, error) {
+	return defaultClient(&frt).GetEmailTemplateDataAsMap(data)
+}
+
+func GetEmailTemplateDataAsMapContext(ctx context.Context, frt FRToken, data []byte) (map[string](interface{}), string, error) {
+	return defaultClient(&frt).GetEmailTemplateDataAsMapContext(ctx, data)
+}
+
 func GetOrigin(tenant string, realm string) string {
 	data := []byte(fmt.Sprintf("%s%s", tenant, realm))
 	hash := md5.Sum(data)
 	return hex.EncodeToString(hash[:])
 }
 
-func GetJourneyData(frt FRToken, journey string) (map[string]interface{}, error) {
-	// var b []byte
+func unmarshalWithoutRev(data []byte, objectName string) (map[string]interface{}, error) {
+	objectMap := make(map[string](interface{}))
+	err := json.Unmarshal(data, &objectMap)
+	if err != nil {
+		return objectMap, errors.New(fmt.Sprintf("ERROR: fail to unmarshal %s json, %s", objectName, err.Error()))
+	}
+	delete(objectMap, "_rev")
+	return objectMap, nil
+}
+
+func (c *Client) GetJourneyData(journey string) (map[string]interface{}, error) {
+	return c.GetJourneyDataContext(context.Background(), journey)
+}
+
+func (c *Client) GetJourneyDataContext(ctx context.Context, journey string) (map[string]interface{}, error) {
+	return c.getJourneyData(ctx, journey, nil)
+}
+
+// fetchAll calls fetch for 0..n-1 on the worker pool. Without a report the first failure
+// aborts, with one every failure is recorded in index order and its data is left nil.
+func (c *Client) fetchAll(ctx context.Context, n int, report *ExportReport, fetch func(ctx context.Context, i int) ([]byte, error)) ([][]byte, error) {
+	data := make([][]byte, n)
+	errs := make([]error, n)
+	err := c.forEach(ctx, n, func(ctx context.Context, i int) error {
+		data[i], errs[i] = fetch(ctx, i)
+		if report != nil && ctx.Err() == nil {
+			return nil
+		}
+		return errs[i]
+	})
+	if err != nil {
+		return data, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			report.add("", "", err)
+		}
+	}
+	return data, nil
+}
+
+// getJourneyData fetches the tree, then its nodes, then the nodes inside page nodes and
+// finally the scripts and email templates they use, each stage on up to c.concurrency
+// goroutines. Every object lands in the export under its id, so the result doesn't
+// depend on the order the calls complete in. With a report, objects that fail are left
+// out of the export and recorded instead.
+func (c *Client) getJourneyData(ctx context.Context, journey string, report *ExportReport) (map[string]interface{}, error) {
 	var journeyMap = make(map[string](interface{}))
-	var treeMap = make(map[string](interface{}))
 	var nodesMap = make(map[string](interface{}))
+	var inPageNodesMap = make(map[string](interface{}))
 	var scriptsMap = make(map[string](interface{}))
 	var emailTemplatesMap = make(map[string](interface{}))
 
-	journeyMap["origin"] = GetOrigin(frt.tenant, frt.realm)
+	journeyMap["origin"] = GetOrigin(c.frt.tenant, c.frt.realm)
 
 	// read tree object
-	treeData, err1 := GetTreeData(frt, journey)
-	if err1 == nil {
-		// exports := []byte(`{"origin":"$ORIGIN", "innernodes":{}, "nodes":{}, "scripts":{}, "emailTemplates":{}}`)
+	treeData, err1 := c.GetTreeDataContext(ctx, journey)
+	if err1 != nil {
+		return journeyMap, err1
+	}
+	treeMap, err := unmarshalWithoutRev(treeData, "tree")
+	if err != nil {
+		return journeyMap, err
+	}
+	var tree Tree
+	err = json.Unmarshal([]byte(treeData), &tree)
+	if err != nil {
+		return journeyMap, errors.New(fmt.Sprintf("ERROR: fail to unmarshal tree json, %s", err.Error()))
+	}
+	journeyMap["tree"] = treeMap
+
+	// sorted so the calls, and which failure is reported, don't depend on map order
+	nodeIds := make([]string, 0, len(tree.Nodes))
+	for nodeId := range tree.Nodes {
+		nodeIds = append(nodeIds, nodeId)
+	}
+	sort.Strings(nodeIds)
+	nodesData, err := c.fetchAll(ctx, len(nodeIds), report, func(ctx context.Context, i int) ([]byte, error) {
+		return c.GetNodeDataContext(ctx, nodeIds[i], tree.Nodes[nodeIds[i]].NodeType)
+	})
+	if err != nil {
+		return journeyMap, err
+	}
 
-		err := json.Unmarshal([]byte(treeData), &treeMap)
+	var nodesInPages []PageNodeChild
+	hasPageNodes := false
+	for i, nodeId := range nodeIds {
+		if nodesData[i] == nil {
+			continue
+		}
+		nodeMap, err := unmarshalWithoutRev(nodesData[i], "nodes")
 		if err != nil {
-			return journeyMap, errors.New(fmt.Sprintf("ERROR: fail to unmarshal tree json, %s", err.Error()))
-		}
-		delete(treeMap, "_rev")
-		journeyMap["tree"] = treeMap
-
-		// iterate over every node in tree
-		nodeList := treeMap["nodes"].(map[string]interface{})
-		for nodeId := range nodeList {
-			nodeInfo := nodeList[nodeId].(map[string]interface{})
-			// log.Printf("key: %s, type: %s\n", nodeId, nodeInfo["nodeType"])
-
-			// get data for node
-			nodeData, _ := GetNodeData(frt, nodeId, nodeInfo["nodeType"].(string))
-			nodeMap := make(map[string](interface{}))
-			err := json.Unmarshal([]byte(nodeData), &nodeMap)
-			// log.Printf("journeyMap: %q\n", journeyMap)
-			if err != nil {
-				return journeyMap, errors.New(fmt.Sprintf("ERROR: fail to unmarshal nodes json, %s", err.Error()))
-			}
-			delete(nodeMap, "_rev")
-			nodesMap[nodeId] = nodeMap
-
-			// if node is scripted node, get the script too
-			_, scriptedType := scriptedNodes[nodeInfo["nodeType"].(string)]
-			if scriptedType {
-				out, id, _ := GetScriptDataAsMap(frt, nodeData)
-				scriptsMap[id] = out
-			}
+			return journeyMap, err
+		}
+		nodesMap[nodeId] = nodeMap
 
-			// if the node is email template, get the template
-			if frt.deploymentType == "Cloud" || frt.deploymentType == "ForgeOps" {
-				_, emailTemplateType := emailTemplateNodes[nodeInfo["nodeType"].(string)]
-				if emailTemplateType {
-					out, id, _ := GetEmailTemplateDataAsMap(frt, nodeData)
-					emailTemplatesMap[id] = out
-				}
+		// handle container nodes (page nodes only)
+		if containerNodes[tree.Nodes[nodeId].NodeType] {
+			var pageNode PageNode
+			err2 := json.Unmarshal(nodesData[i], &pageNode)
+			if err2 != nil {
+				return journeyMap, errors.New(fmt.Sprintf("ERROR: fail to unmarshal page node json, %s", err2.Error()))
 			}
+			nodesInPages = append(nodesInPages, pageNode.Nodes...)
+			hasPageNodes = true
+		}
+	}
 
-			// handle container nodes (page nodes only)
-			_, containerNodeType := containerNodes[nodeInfo["nodeType"].(string)]
-			if containerNodeType {
-				// log.Printf("%s is page node\n", nodeId)
-				// log.Printf("nodeData: %s\n", nodeData)
-				pageNodeMap := make(map[string](interface{}))
-				err2 := json.Unmarshal([]byte(nodeData), &pageNodeMap)
-				if err2 != nil {
-					return journeyMap, errors.New(fmt.Sprintf("ERROR: fail to unmarshal page node json, %s", err.Error()))
-				}
-
-				inPageNodesMap := make(map[string](interface{}))
-				nodesInPage := pageNodeMap["nodes"].([]interface{})
-				for index, _ := range nodesInPage {
-					nodesInPageMap := nodesInPage[index].(map[string]interface{})
-					nodeIdInPage := nodesInPageMap["_id"].(string)
-					nodeTypeInPage := nodesInPageMap["nodeType"].(string)
-					inPageNodeData, _ := GetNodeData(frt, nodeIdInPage, nodeTypeInPage)
-					// log.Printf("inPageNodeData: %s\n", inPageNodeData)
-
-					inPageNodeMap := make(map[string](interface{}))
-					err := json.Unmarshal([]byte(inPageNodeData), &inPageNodeMap)
-					// log.Printf("journeyMap: %q\n", journeyMap)
-					if err != nil {
-						return journeyMap, errors.New(fmt.Sprintf("ERROR: fail to unmarshal page node data json, %s", err.Error()))
-					}
-					delete(inPageNodeMap, "_rev")
-					inPageNodesMap[nodeIdInPage] = inPageNodeMap
-
-					// handle scripted nodes in page node
-					_, scriptedPageNdeType := scriptedNodes[nodeTypeInPage]
-					if scriptedPageNdeType {
-						out, id, _ := GetScriptDataAsMap(frt, inPageNodeData)
-						scriptsMap[id] = out
-					}
+	inPageNodesData, err := c.fetchAll(ctx, len(nodesInPages), report, func(ctx context.Context, i int) ([]byte, error) {
+		return c.GetNodeDataContext(ctx, nodesInPages[i].Id, nodesInPages[i].NodeType)
+	})
+	if err != nil {
+		return journeyMap, err
+	}
+	for i, nodeInPage := range nodesInPages {
+		if inPageNodesData[i] == nil {
+			continue
+		}
+		inPageNodeMap, err := unmarshalWithoutRev(inPageNodesData[i], "page node data")
+		if err != nil {
+			return journeyMap, err
+		}
+		inPageNodesMap[nodeInPage.Id] = inPageNodeMap
+	}
+	if hasPageNodes {
+		journeyMap["innernodes"] = inPageNodesMap
+	}
 
-					if frt.deploymentType == "Cloud" || frt.deploymentType == "ForgeOps" {
-						_, emailTemplatePageNodeType := emailTemplateNodes[nodeTypeInPage]
-						if emailTemplatePageNodeType {
-							out, id, _ := GetEmailTemplateDataAsMap(frt, inPageNodeData)
-							emailTemplatesMap[id] = out
+	// collect the scripts and email templates used by all nodes, each is fetched once
+	var scriptIds, templateIds []string
+	seen := make(map[string]bool)
+	collect := func(nodeType string, nodeData []byte) error {
+		withTemplate := emailTemplateNodes[nodeType] && (c.frt.deploymentType == "Cloud" || c.frt.deploymentType == "ForgeOps")
+		if nodeData == nil || (!scriptedNodes[nodeType] && !withTemplate) {
+			return nil
+		}
+		var node Node
+		err := json.Unmarshal(nodeData, &node)
+		if err != nil {
+			return errors.New(fmt.Sprintf("ERROR: fail to unmarshal node json, %s", err.Error()))
+		}
+		if scriptId, exists := node.StringProperty("script"); scriptedNodes[nodeType] && exists && !seen["script/"+scriptId] {
+			seen["script/"+scriptId] = true
+			scriptIds = append(scriptIds, scriptId)
+		}
+		if templateId, exists := node.StringProperty("emailTemplateName"); withTemplate && exists && !seen["emailTemplate/"+templateId] {
+			seen["emailTemplate/"+templateId] = true
+			templateIds = append(templateIds, templateId)
+		}
+		return nil
+	}
+	for i, nodeId := range nodeIds {
+		err := collect(tree.Nodes[nodeId].NodeType, nodesData[i])
+		if err != nil {
+			return journeyMap, err
+		}
+	}
+	for i, nodeInPage := range nodesInPages {
+		err := collect(nodeInPage.NodeType, inPageNodesData[i])
+		if err != nil {
+			return journeyMap, err
+		}
+	}
 
-						}
-					}
-				}
-				journeyMap["innernodes"] = inPageNodesMap
-			}
+	dependenciesData, err := c.fetchAll(ctx, len(scriptIds)+len(templateIds), report, func(ctx context.Context, i int) ([]byte, error) {
+		if i < len(scriptIds) {
+			return c.GetScriptDataContext(ctx, scriptIds[i])
+		}
+		return c.GetEmailTemplateDataContext(ctx, templateIds[i-len(scriptIds)])
+	})
+	if err != nil {
+		return journeyMap, err
+	}
+	scriptsData, templatesData := dependenciesData[:len(scriptIds)], dependenciesData[len(scriptIds):]
+	for i, scriptId := range scriptIds {
+		if scriptsData[i] == nil {
+			continue
 		}
-		journeyMap["scripts"] = scriptsMap
-		journeyMap["emailTemplates"] = emailTemplatesMap
-		journeyMap["nodes"] = nodesMap
-		// log.Printf("journeyMap: %q\n", journeyMap)
+		scriptMap, err := unmarshalWithoutRev(scriptsData[i], "script data")
+		if err != nil {
+			return journeyMap, err
+		}
+		scriptsMap[scriptId] = scriptMap
+	}
+	for i, templateId := range templateIds {
+		if templatesData[i] == nil {
+			continue
+		}
+		templateMap, err := unmarshalWithoutRev(templatesData[i], "email template data")
+		if err != nil {
+			return journeyMap, err
+		}
+		emailTemplatesMap[templateId] = templateMap
+	}
 
-		// callbacks[index].(map[string]interface{})
-		return journeyMap, nil
-	} else {
-		return journeyMap, errors.New(fmt.Sprintf("ERROR: error exporting journey, %s\n", err1.Error()))
+	journeyMap["scripts"] = scriptsMap
+	journeyMap["emailTemplates"] = emailTemplatesMap
+	journeyMap["nodes"] = nodesMap
+	return journeyMap, nil
+}
+
+func GetJourneyData(frt FRToken, journey string) (map[string]interface{}, error) {
+	return defaultClient(&frt).GetJourneyData(journey)
+}
+
+func GetJourneyDataContext(ctx context.Context, frt FRToken, journey string) (map[string]interface{}, error) {
+	return defaultClient(&frt).GetJourneyDataContext(ctx, journey)
+}
+
+type ExportJourneyOptions struct {
+	// follow InnerTreeEvaluatorNodes and embed each inner tree's export under "innerTrees"
+	Deep bool
+	// leave out nodes, scripts, email templates and inner trees that fail to export
+	// instead of aborting, each failure is added to Report when it is set
+	Lenient bool
+	Report  *ExportReport
+}
+
+func (c *Client) GetJourneyDataWithOptions(journey string, opts ExportJourneyOptions) (map[string]interface{}, error) {
+	return c.GetJourneyDataWithOptionsContext(context.Background(), journey, opts)
+}
+
+func (c *Client) GetJourneyDataWithOptionsContext(ctx context.Context, journey string, opts ExportJourneyOptions) (map[string]interface{}, error) {
+	if opts.Lenient && opts.Report == nil {
+		opts.Report = &ExportReport{}
 	}
+	return c.getJourneyDataWithOptions(ctx, journey, opts, make(map[string]bool))
 }
 
-func IsCustom(frt FRToken, treeMap map[string](interface{})) bool {
-	nodeList := treeMap["nodes"].(map[string]interface{})
+func GetJourneyDataWithOptions(frt FRToken, journey string, opts ExportJourneyOptions) (map[string]interface{}, error) {
+	return defaultClient(&frt).GetJourneyDataWithOptions(journey, opts)
+}
+
+func GetJourneyDataWithOptionsContext(ctx context.Context, frt FRToken, journey string, opts ExportJourneyOptions) (map[string]interface{}, error) {
+	return defaultClient(&frt).GetJourneyDataWithOptionsContext(ctx, journey, opts)
+}
+
+func (c *Client) getJourneyDataWithOptions(ctx context.Context, journey string, opts ExportJourneyOptions, visited map[string]bool) (map[string]interface{}, error) {
+	visited[journey] = true
+	var report *ExportReport
+	if opts.Lenient {
+		report = opts.Report
+	}
+	journeyMap, err := c.getJourneyData(ctx, journey, report)
+	if err != nil || !opts.Deep {
+		return journeyMap, err
+	}
+	innerTreesMap := make(map[string](interface{}))
+	for _, innerTree := range GetInnerTrees(journeyMap) {
+		// each tree is exported once, which also stops inner tree cycles
+		if visited[innerTree] {
+			continue
+		}
+		innerJourneyMap, err := c.getJourneyDataWithOptions(ctx, innerTree, opts, visited)
+		if err != nil && report != nil && ctx.Err() == nil {
+			report.add("tree", innerTree, err)
+			continue
+		}
+		if err != nil {
+			return journeyMap, err
+		}
+		innerTreesMap[innerTree] = innerJourneyMap
+	}
+	journeyMap["innerTrees"] = innerTreesMap
+	return journeyMap, nil
+}
+
+// IsCustom reports whether the tree uses nodes that don't ship with its AM version. Trees
+// that can't be checked count as custom, use CheckCustom to get the error instead.
+func (c *Client) IsCustom(treeMap map[string](interface{})) bool {
+	return c.IsCustomContext(context.Background(), treeMap)
+}
+
+func (c *Client) IsCustomContext(ctx context.Context, treeMap map[string](interface{})) bool {
+	custom, err := c.CheckCustomContext(ctx, treeMap)
+	return custom || err != nil
+}
+
+func (c *Client) CheckCustom(treeMap map[string](interface{})) (bool, error) {
+	return c.CheckCustomContext(context.Background(), treeMap)
+}
+
+func (c *Client) CheckCustomContext(ctx context.Context, treeMap map[string](interface{})) (bool, error) {
+	var tree Tree
+	err := convertJSON(treeMap, &tree)
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("ERROR: fail to read tree, %s", err.Error()))
+	}
 	var ootbNodeTypes map[string]bool
-	// fmt.Println(frt.version)
-	switch frt.version {
+	// fmt.Println(c.frt.version)
+	switch c.frt.version {
 	case "7.1.0", "7.2.0":
 		ootbNodeTypes = ootbnodetypes_7_1
 	case "7.0.0", "7.0.1", "7.0.2":
@@ -327,79 +567,160 @@ func IsCustom(frt FRToken, treeMap map[string](interface{})) bool {
 	case "6.0.0.7", "6.0.0.6", "6.0.0.5", "6.0.0.4", "6.0.0.3", "6.0.0.2", "6.0.0.1", "6.0.0":
 		ootbNodeTypes = ootbnodetypes_6
 	default:
-		return true
+		return true, nil
 	}
 
 	// log.Printf("ootbNodeTypes: %q\n", ootbNodeTypes)
-	for nodeId := range nodeList {
-		nodeInfo := nodeList[nodeId].(map[string]interface{})
-		// fmt.Printf("nodeInfo: %s and %b\n", nodeInfo, ootbNodeTypes[nodeInfo["nodeType"].(string)])
-		_, ootbnode := ootbNodeTypes[nodeInfo["nodeType"].(string)]
+	for nodeId, nodeInfo := range tree.Nodes {
+		// fmt.Printf("nodeInfo: %s and %b\n", nodeInfo, ootbNodeTypes[nodeInfo.NodeType])
+		_, ootbnode := ootbNodeTypes[nodeInfo.NodeType]
 		if !ootbnode {
-			return true
+			return true, nil
 		}
-		_, containerNodeType := containerNodes[nodeInfo["nodeType"].(string)]
+		_, containerNodeType := containerNodes[nodeInfo.NodeType]
 		if containerNodeType {
-			nodeData, _ := GetNodeData(frt, nodeId, nodeInfo["nodeType"].(string))
-			pageNodeMap := make(map[string](interface{}))
-			err := json.Unmarshal([]byte(nodeData), &pageNodeMap)
+			pageNode, err := c.GetPageNodeContext(ctx, nodeId, nodeInfo.NodeType)
 			if err != nil {
-				return true
+				return false, err
 			}
-			nodesInPage := pageNodeMap["nodes"].([]interface{})
-			for index, _ := range nodesInPage {
-				nodesInPageMap := nodesInPage[index].(map[string]interface{})
-				// nodeIdInPage := nodesInPageMap["_id"].(string)
-				_, ootbnode := ootbNodeTypes[nodesInPageMap["nodeType"].(string)]
+			for _, nodeInPage := range pageNode.Nodes {
+				_, ootbnode := ootbNodeTypes[nodeInPage.NodeType]
 				if !ootbnode {
-					return true
+					return true, nil
 				}
 			}
 		}
 	}
-	return false
+	return false, nil
 }
 
-func ListJourneys(frt FRToken) (map[string]bool, error) {
+func IsCustom(frt FRToken, treeMap map[string](interface{})) bool {
+	return defaultClient(&frt).IsCustom(treeMap)
+}
+
+func IsCustomContext(ctx context.Context, frt FRToken, treeMap map[string](interface{})) bool {
+	return defaultClient(&frt).IsCustomContext(ctx, treeMap)
+}
+
+func CheckCustom(frt FRToken, treeMap map[string](interface{})) (bool, error) {
+	return defaultClient(&frt).CheckCustom(treeMap)
+}
+
+func CheckCustomContext(ctx context.Context, frt FRToken, treeMap map[string](interface{})) (bool, error) {
+	return defaultClient(&frt).CheckCustomContext(ctx, treeMap)
+}
+
+// ListJourneys maps the name of every tree in the realm to whether it is custom, as
+// reported by IsCustom. Trees that can't be checked count as custom, use
+// ListJourneysWithReport to learn which those are.
+func (c *Client) ListJourneys() (map[string]bool, error) {
+	return c.ListJourneysContext(context.Background())
+}
+
+func (c *Client) ListJourneysContext(ctx context.Context) (map[string]bool, error) {
+	return c.ListJourneysWithReportContext(ctx, nil)
+}
+
+// ListJourneysWithReport is ListJourneys adding the failure of every tree that can't be
+// checked to report.
+func (c *Client) ListJourneysWithReport(report *ExportReport) (map[string]bool, error) {
+	return c.ListJourneysWithReportContext(context.Background(), report)
+}
 
+func (c *Client) ListJourneysWithReportContext(ctx context.Context, report *ExportReport) (map[string]bool, error) {
+	trees, err := c.queryTrees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make(map[string]bool)
+	for _, treeMap := range trees {
+		treeName, _ := treeMap["_id"].(string)
+		customTree, err := c.CheckCustomContext(ctx, treeMap)
+		if err != nil && report != nil {
+			// the tree is what couldn't be listed, whichever of its nodes failed
+			report.Failures = append(report.Failures, &ObjectError{Kind: "tree", Id: treeName, Err: err})
+		}
+		list[treeName] = customTree || err != nil
+	}
+	return list, nil
+}
+
+// queryTrees returns every tree of the realm, with its nodes but not their configuration.
+func (c *Client) queryTrees(ctx context.Context) ([]map[string]interface{}, error) {
 	// log.Printf("Cookie name: %s\n", cookieName)
-	client := resty.New()
-	// client.SetDebug(true)
-	jURL := fmt.Sprintf(queryAllTreesURLTemplate, frt.tenant, GetRealmUrl(frt.realm))
+	jURL := c.amURL(queryAllTreesURLTemplate)
 	// fmt.Printf("url: %s\n", jURL)
-	resp1, err1 := client.R().
-		SetHeader("Accept-API-Version", amApiVersion).
-		SetHeader("X-Requested-With", "XmlHttpRequest").
-		SetCookie(&http.Cookie{Name: frt.cookieName, Value: frt.tokenId}).
+	resp1, err1 := c.amRequest(ctx).
 		Get(jURL)
 
 	if err1 == nil {
 		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
-			return nil, errors.New(fmt.Sprintf("ERROR: list journeys call returned %d, possible cause: invalid credentials", resp1.StatusCode()))
+			return nil, newAPIError(resp1, fmt.Sprintf("ERROR: list journeys call returned %d, possible cause: invalid credentials", resp1.StatusCode()))
 		} else {
-			jsonMap := make(map[string](interface{}))
-			err := json.Unmarshal([]byte(resp1.Body()), &jsonMap)
+			results, err := resultsFromBody(resp1.Body())
 			if err != nil {
-				return nil, errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err.Error()))
+				return nil, err
 			}
-			results, _ := jsonMap["result"].([]interface{})
-			list := make(map[string]bool)
+			trees := make([]map[string]interface{}, 0, len(results))
 			for index, _ := range results {
-				resultMap := results[index].(map[string]interface{})
-				customTree := false
-				// fmt.Printf("%s, %s, %s, %s, %s, %s\n", frt.tenant, frt.realm, frt.cookieName, frt.tokenId, frt.bearerToken, frt.version)
-				if IsCustom(frt, resultMap) {
-					customTree = true
+				resultMap, ok := results[index].(map[string]interface{})
+				if !ok {
+					continue
 				}
-				list[resultMap["_id"].(string)] = customTree
+				trees = append(trees, resultMap)
 			}
-			return list, nil
+			return trees, nil
 		}
 	} else {
 		return nil, errors.New(fmt.Sprintf("ERROR: error getting journey list, %s\n", err1.Error()))
 	}
 }
 
+// ListJourneyNames returns the sorted names of the trees in the realm. Unlike ListJourneys it
+// doesn't look at the nodes of each tree, so one broken tree doesn't fail the listing.
+func (c *Client) ListJourneyNames() ([]string, error) {
+	return c.ListJourneyNamesContext(context.Background())
+}
+
+func (c *Client) ListJourneyNamesContext(ctx context.Context) ([]string, error) {
+	trees, err := c.queryTrees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(trees))
+	for _, treeMap := range trees {
+		if treeName, ok := treeMap["_id"].(string); ok {
+			names = append(names, treeName)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func ListJourneyNames(frt FRToken) ([]string, error) {
+	return defaultClient(&frt).ListJourneyNames()
+}
+
+func ListJourneyNamesContext(ctx context.Context, frt FRToken) ([]string, error) {
+	return defaultClient(&frt).ListJourneyNamesContext(ctx)
+}
+
+func ListJourneys(frt FRToken) (map[string]bool, error) {
+	return defaultClient(&frt).ListJourneys()
+}
+
+func ListJourneysContext(ctx context.Context, frt FRToken) (map[string]bool, error) {
+	return defaultClient(&frt).ListJourneysContext(ctx)
+}
+
+func ListJourneysWithReport(frt FRToken, report *ExportReport) (map[string]bool, error) {
+	return defaultClient(&frt).ListJourneysWithReport(report)
+}
+
+func ListJourneysWithReportContext(ctx context.Context, frt FRToken, report *ExportReport) (map[string]bool, error) {
+	return defaultClient(&frt).ListJourneysWithReportContext(ctx, report)
+}
+
 // func GetNodeType(treeDataMap map[string]interface{}) {
 
 // }
@@ -408,47 +729,972 @@ func DescribeTree(journeyMap map[string]interface{}) map[string]interface{} {
 	treeMap := make(map[string]interface{})
 	nodeTypeMap := make(map[string]int)
 	scriptMap := make(map[string]string)
-	treeName := journeyMap["tree"].(map[string]interface{})["_id"]
-	// log.Printf("treename = %s\n", treeName)
-	for nodeId := range journeyMap["nodes"].(map[string]interface{}) {
-		// log.Printf("nodeId = %s\n", nodeId)
-		nodeType := journeyMap["nodes"].(map[string]interface{})[nodeId].(map[string]interface{})["_type"].(map[string]interface{})["_id"]
-		_, exists := nodeTypeMap[nodeType.(string)]
-		if exists {
-			nodeTypeMap[nodeType.(string)] += 1
-		} else {
-			nodeTypeMap[nodeType.(string)] = 1
-		}
-		// log.Printf("nodeType = %s\n", nodeType)
-	}
-	if journeyMap["innernodes"] != nil {
-        for nodeId := range journeyMap["innernodes"].(map[string]interface{}) {
-            // log.Printf("nodeId = %s\n", nodeId)
-            nodeType := journeyMap["innernodes"].(map[string]interface{})[nodeId].(map[string]interface{})["_type"].(map[string]interface{})["_id"]
-            _, exists := nodeTypeMap[nodeType.(string)]
-            if exists {
-                nodeTypeMap[nodeType.(string)] += 1
-            } else {
-                nodeTypeMap[nodeType.(string)] = 1
-            }
-            // log.Printf("nodeType = %s\n", nodeType)
-        }
-    }
-
+	journey, err := JourneyFromMap(journeyMap)
+	if err != nil {
+		return treeMap
+	}
+	// log.Printf("treename = %s\n", journey.Tree.Id)
+	for _, node := range journey.Nodes {
+		nodeTypeMap[node.Type.Id] += 1
+	}
+	for _, node := range journey.InnerNodes {
+		nodeTypeMap[node.Type.Id] += 1
+	}
 	// log.Printf("nodeTypeMap: %q\n", nodeTypeMap)
-    if journeyMap["scripts"] != nil {
-        for scriptId := range journeyMap["scripts"].(map[string]interface{}) {
-            description := journeyMap["scripts"].(map[string]interface{})[scriptId].(map[string]interface{})["description"]
-            if description == nil {
-                description = ""
-            }
-            scriptMap[journeyMap["scripts"].(map[string]interface{})[scriptId].(map[string]interface{})["name"].(string)] =
-                description.(string)
-        }
-    }
+	for _, script := range journey.Scripts {
+		scriptMap[script.Name] = script.Description
+	}
 	// log.Printf("scriptMap: %q\n", scriptMap)
-	treeMap["treeName"] = treeName
+	treeMap["treeName"] = journey.Tree.Id
 	treeMap["nodeTypes"] = nodeTypeMap
 	treeMap["scripts"] = scriptMap
 	return treeMap
 }
+
+func (c *Client) putAMObject(ctx context.Context, jURL string, data []byte, objectName string) ([]byte, error) {
+	var b []byte
+	resp1, err1 := c.amRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(data).
+		Put(jURL)
+	if err1 == nil {
+		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+			return b, newAPIError(resp1, fmt.Sprintf("ERROR: put %s call returned %d, %s", objectName, resp1.StatusCode(), resp1.Body()))
+		} else {
+			return resp1.Body(), nil
+		}
+	} else {
+		return b, errors.New(fmt.Sprintf("ERROR: error putting %s, %s\n", objectName, err1.Error()))
+	}
+}
+
+func (c *Client) PutNodeData(id string, nodeType string, data []byte) ([]byte, error) {
+	return c.PutNodeDataContext(context.Background(), id, nodeType, data)
+}
+
+func (c *Client) PutNodeDataContext(ctx context.Context, id string, nodeType string, data []byte) ([]byte, error) {
+	jURL := c.amURL(nodeURLTemplate, nodeType, id)
+	return c.putAMObject(ctx, jURL, data, "node")
+}
+
+func PutNodeData(frt FRToken, id string, nodeType string, data []byte) ([]byte, error) {
+	return defaultClient(&frt).PutNodeData(id, nodeType, data)
+}
+
+func PutNodeDataContext(ctx context.Context, frt FRToken, id string, nodeType string, data []byte) ([]byte, error) {
+	return defaultClient(&frt).PutNodeDataContext(ctx, id, nodeType, data)
+}
+
+func (c *Client) PutTreeData(name string, data []byte) ([]byte, error) {
+	return c.PutTreeDataContext(context.Background(), name, data)
+}
+
+func (c *Client) PutTreeDataContext(ctx context.Context, name string, data []byte) ([]byte, error) {
+	jURL := c.amURL(journeyURLTemplate, name)
+	return c.putAMObject(ctx, jURL, data, "tree")
+}
+
+func PutTreeData(frt FRToken, name string, data []byte) ([]byte, error) {
+	return defaultClient(&frt).PutTreeData(name, data)
+}
+
+func PutTreeDataContext(ctx context.Context, frt FRToken, name string, data []byte) ([]byte, error) {
+	return defaultClient(&frt).PutTreeDataContext(ctx, name, data)
+}
+
+func (c *Client) PutScriptData(id string, data []byte) ([]byte, error) {
+	return c.PutScriptDataContext(context.Background(), id, data)
+}
+
+func (c *Client) PutScriptDataContext(ctx context.Context, id string, data []byte) ([]byte, error) {
+	jURL := c.amURL(scriptURLTemplate, id)
+	return c.putAMObject(ctx, jURL, data, "script")
+}
+
+func PutScriptData(frt FRToken, id string, data []byte) ([]byte, error) {
+	return defaultClient(&frt).PutScriptData(id, data)
+}
+
+func PutScriptDataContext(ctx context.Context, frt FRToken, id string, data []byte) ([]byte, error) {
+	return defaultClient(&frt).PutScriptDataContext(ctx, id, data)
+}
+
+func (c *Client) PutEmailTemplateData(id string, data []byte) ([]byte, error) {
+	return c.PutEmailTemplateDataContext(context.Background(), id, data)
+}
+
+func (c *Client) PutEmailTemplateDataContext(ctx context.Context, id string, data []byte) ([]byte, error) {
+	var b []byte
+	jURL := c.idmURL(emailTemplateURLTemplate, id)
+	resp1, err1 := c.idmRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(data).
+		Put(jURL)
+	if err1 == nil {
+		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+			return b, newAPIError(resp1, fmt.Sprintf("ERROR: put email template call returned %d, %s", resp1.StatusCode(), resp1.Body()))
+		} else {
+			return resp1.Body(), nil
+		}
+	} else {
+		return b, errors.New(fmt.Sprintf("ERROR: error putting email template, %s\n", err1.Error()))
+	}
+}
+
+func PutEmailTemplateData(frt FRToken, id string, data []byte) ([]byte, error) {
+	return defaultClient(&frt).PutEmailTemplateData(id, data)
+}
+
+func PutEmailTemplateDataContext(ctx context.Context, frt FRToken, id string, data []byte) ([]byte, error) {
+	return defaultClient(&frt).PutEmailTemplateDataContext(ctx, id, data)
+}
+
+func getNodeType(nodeMap map[string]interface{}) (string, error) {
+	typeMap, ok := nodeMap["_type"].(map[string]interface{})
+	if !ok {
+		return "", errors.New(fmt.Sprintf("ERROR: node %v has no _type", nodeMap["_id"]))
+	}
+	nodeType, ok := typeMap["_id"].(string)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("ERROR: node %v has no _type._id", nodeMap["_id"]))
+	}
+	return nodeType, nil
+}
+
+func marshalWithoutRev(object interface{}) ([]byte, error) {
+	objectMap, ok := object.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("ERROR: unexpected object %v", object))
+	}
+	cleanMap := make(map[string]interface{}, len(objectMap))
+	for key, value := range objectMap {
+		if key != "_rev" {
+			cleanMap[key] = value
+		}
+	}
+	data, err := json.Marshal(cleanMap)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ERROR: fail to marshal json, %s", err.Error()))
+	}
+	return data, nil
+}
+
+func (c *Client) importNodes(ctx context.Context, nodes interface{}) error {
+	if nodes == nil {
+		return nil
+	}
+	nodesMap, ok := nodes.(map[string]interface{})
+	if !ok {
+		return errors.New(fmt.Sprintf("ERROR: unexpected nodes object %v", nodes))
+	}
+	for nodeId, node := range nodesMap {
+		nodeMap, ok := node.(map[string]interface{})
+		if !ok {
+			return errors.New(fmt.Sprintf("ERROR: unexpected node object %s", nodeId))
+		}
+		nodeType, err := getNodeType(nodeMap)
+		if err != nil {
+			return err
+		}
+		nodeData, err := marshalWithoutRev(nodeMap)
+		if err != nil {
+			return err
+		}
+		_, err = c.PutNodeDataContext(ctx, nodeId, nodeType, nodeData)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) importScripts(ctx context.Context, scripts interface{}) error {
+	scriptsMap, _ := scripts.(map[string]interface{})
+	for scriptId, script := range scriptsMap {
+		scriptData, err := marshalWithoutRev(script)
+		if err != nil {
+			return err
+		}
+		_, err = c.PutScriptDataContext(ctx, scriptId, scriptData)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) importEmailTemplates(ctx context.Context, emailTemplates interface{}) error {
+	// email templates only live in IDM
+	if c.frt.deploymentType != "Cloud" && c.frt.deploymentType != "ForgeOps" {
+		return nil
+	}
+	emailTemplatesMap, _ := emailTemplates.(map[string]interface{})
+	for templateId, template := range emailTemplatesMap {
+		templateData, err := marshalWithoutRev(template)
+		if err != nil {
+			return err
+		}
+		_, err = c.PutEmailTemplateDataContext(ctx, templateId, templateData)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type OriginPolicy int
+
+const (
+	// the zero value, so an import never overwrites another tenant's nodes by accident
+	OriginPolicyRefuse OriginPolicy = iota
+	OriginPolicyKeep
+	OriginPolicyRemap
+)
+
+type ImportJourneyOptions struct {
+	// generate new ids for all nodes so an existing copy of the journey is not overwritten
+	ReUUID bool
+	// what to do with node ids when the export came from a different tenant or realm,
+	// unless ReUUID is set. Defaults to OriginPolicyRefuse, choose OriginPolicyKeep to
+	// import with the exported ids or OriginPolicyRemap for new ones.
+	ForeignOriginPolicy OriginPolicy
+}
+
+func IsSameOrigin(frt FRToken, journeyMap map[string]interface{}) bool {
+	origin, _ := journeyMap["origin"].(string)
+	return origin == GetOrigin(frt.tenant, frt.realm)
+}
+
+func copyJourneyMap(journeyMap map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(journeyMap)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ERROR: fail to marshal json, %s", err.Error()))
+	}
+	copyMap := make(map[string]interface{})
+	err = json.Unmarshal(data, &copyMap)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err.Error()))
+	}
+	return copyMap, nil
+}
+
+func remapNodes(nodes interface{}, idMap map[string]string) map[string]interface{} {
+	remapped := make(map[string]interface{})
+	nodesMap, _ := nodes.(map[string]interface{})
+	for nodeId, node := range nodesMap {
+		newId := idMap[nodeId]
+		if nodeMap, ok := node.(map[string]interface{}); ok {
+			nodeMap["_id"] = newId
+			// page nodes list their children by id
+			if nodesInPage, ok := nodeMap["nodes"].([]interface{}); ok {
+				for index := range nodesInPage {
+					if nodeInPageMap, ok := nodesInPage[index].(map[string]interface{}); ok {
+						if childId, ok := nodeInPageMap["_id"].(string); ok {
+							if newChildId, exists := idMap[childId]; exists {
+								nodeInPageMap["_id"] = newChildId
+							}
+						}
+					}
+				}
+			}
+		}
+		remapped[newId] = node
+	}
+	return remapped
+}
+
+// RemapNodeIds returns a copy of journeyMap with fresh ids for every node and inner node.
+// Connections to nodes outside the journey (e.g. the success and failure nodes) are left alone.
+func RemapNodeIds(journeyMap map[string]interface{}) (map[string]interface{}, error) {
+	copyMap, err := copyJourneyMap(journeyMap)
+	if err != nil {
+		return nil, err
+	}
+	idMap := make(map[string]string)
+	for _, key := range []string{"nodes", "innernodes"} {
+		nodesMap, _ := copyMap[key].(map[string]interface{})
+		for nodeId := range nodesMap {
+			newId, err := GenerateUUID()
+			if err != nil {
+				return nil, err
+			}
+			idMap[nodeId] = newId
+		}
+	}
+	for _, key := range []string{"nodes", "innernodes"} {
+		if copyMap[key] != nil {
+			copyMap[key] = remapNodes(copyMap[key], idMap)
+		}
+	}
+
+	treeMap, ok := copyMap["tree"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("ERROR: journey has no tree")
+	}
+	if entryNodeId, ok := treeMap["entryNodeId"].(string); ok {
+		if newId, exists := idMap[entryNodeId]; exists {
+			treeMap["entryNodeId"] = newId
+		}
+	}
+	treeNodes, _ := treeMap["nodes"].(map[string]interface{})
+	newTreeNodes := make(map[string]interface{})
+	for nodeId, nodeRef := range treeNodes {
+		newId, exists := idMap[nodeId]
+		if !exists {
+			newId = nodeId
+		}
+		if nodeRefMap, ok := nodeRef.(map[string]interface{}); ok {
+			if connections, ok := nodeRefMap["connections"].(map[string]interface{}); ok {
+				for outcome, target := range connections {
+					if targetId, ok := target.(string); ok {
+						if newTargetId, exists := idMap[targetId]; exists {
+							connections[outcome] = newTargetId
+						}
+					}
+				}
+			}
+		}
+		newTreeNodes[newId] = nodeRef
+	}
+	treeMap["nodes"] = newTreeNodes
+	return copyMap, nil
+}
+
+// ImportJourney imports with the default ImportJourneyOptions, so an export from another
+// tenant or realm is refused.
+func (c *Client) ImportJourney(journeyMap map[string]interface{}) error {
+	return c.ImportJourneyContext(context.Background(), journeyMap)
+}
+
+func (c *Client) ImportJourneyContext(ctx context.Context, journeyMap map[string]interface{}) error {
+	return c.ImportJourneyWithOptionsContext(ctx, journeyMap, ImportJourneyOptions{})
+}
+
+func ImportJourney(frt FRToken, journeyMap map[string]interface{}) error {
+	return defaultClient(&frt).ImportJourney(journeyMap)
+}
+
+func ImportJourneyContext(ctx context.Context, frt FRToken, journeyMap map[string]interface{}) error {
+	return defaultClient(&frt).ImportJourneyContext(ctx, journeyMap)
+}
+
+func (c *Client) ImportJourneyWithOptions(journeyMap map[string]interface{}, opts ImportJourneyOptions) error {
+	return c.ImportJourneyWithOptionsContext(context.Background(), journeyMap, opts)
+}
+
+func (c *Client) ImportJourneyWithOptionsContext(ctx context.Context, journeyMap map[string]interface{}, opts ImportJourneyOptions) error {
+	err := c.checkOrigins(journeyMap, opts)
+	if err != nil {
+		return err
+	}
+	return c.importJourney(ctx, journeyMap, opts)
+}
+
+// originReUUID tells whether the nodes of an export from origin get new ids, or refuses
+// the import when it is foreign and opts don't allow that.
+func (c *Client) originReUUID(origin interface{}, opts ImportJourneyOptions) (bool, error) {
+	if opts.ReUUID {
+		return true, nil
+	}
+	if IsSameOrigin(*c.frt, map[string]interface{}{"origin": origin}) {
+		return false, nil
+	}
+	switch opts.ForeignOriginPolicy {
+	case OriginPolicyRemap:
+		return true, nil
+	case OriginPolicyRefuse:
+		return false, errors.New(fmt.Sprintf("ERROR: journey was exported from a different tenant or realm (origin %v), refusing to import\nset ForeignOriginPolicy to OriginPolicyKeep or OriginPolicyRemap to import it anyway", origin))
+	}
+	return false, nil
+}
+
+// checkOrigins applies the origin policy to a journey and all its embedded inner trees
+// before anything is written, so a refused import leaves the tenant untouched.
+func (c *Client) checkOrigins(journeyMap map[string]interface{}, opts ImportJourneyOptions) error {
+	_, err := c.originReUUID(journeyMap["origin"], opts)
+	if err != nil {
+		return err
+	}
+	innerTreesMap, _ := journeyMap["innerTrees"].(map[string]interface{})
+	for innerTree, innerJourney := range innerTreesMap {
+		innerJourneyMap, ok := innerJourney.(map[string]interface{})
+		if !ok {
+			return errors.New(fmt.Sprintf("ERROR: unexpected inner tree object %s", innerTree))
+		}
+		err = c.checkOrigins(innerJourneyMap, opts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importJourney writes a journey whose origins checkOrigins accepted
+func (c *Client) importJourney(ctx context.Context, journeyMap map[string]interface{}, opts ImportJourneyOptions) error {
+	// inner trees embedded by a deep export go in before the tree calling them
+	if innerTreesMap, exists := journeyMap["innerTrees"].(map[string]interface{}); exists {
+		innerTrees := make([]string, 0, len(innerTreesMap))
+		for innerTree := range innerTreesMap {
+			innerTrees = append(innerTrees, innerTree)
+		}
+		sort.Strings(innerTrees)
+		for _, innerTree := range innerTrees {
+			innerJourneyMap, ok := innerTreesMap[innerTree].(map[string]interface{})
+			if !ok {
+				return errors.New(fmt.Sprintf("ERROR: unexpected inner tree object %s", innerTree))
+			}
+			err := c.importJourney(ctx, innerJourneyMap, opts)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	reUUID, err := c.originReUUID(journeyMap["origin"], opts)
+	if err != nil {
+		return err
+	}
+	if reUUID {
+		remapped, err := RemapNodeIds(journeyMap)
+		if err != nil {
+			return err
+		}
+		journeyMap = remapped
+	}
+
+	treeMap, ok := journeyMap["tree"].(map[string]interface{})
+	if !ok {
+		return errors.New("ERROR: journey has no tree")
+	}
+	treeName, ok := treeMap["_id"].(string)
+	if !ok {
+		return errors.New("ERROR: tree has no _id")
+	}
+
+	// scripts first, nodes reference them
+	err = c.importScripts(ctx, journeyMap["scripts"])
+	if err != nil {
+		return err
+	}
+	err = c.importEmailTemplates(ctx, journeyMap["emailTemplates"])
+	if err != nil {
+		return err
+	}
+
+	// inner nodes must exist before the page nodes that contain them
+	err = c.importNodes(ctx, journeyMap["innernodes"])
+	if err != nil {
+		return err
+	}
+	err = c.importNodes(ctx, journeyMap["nodes"])
+	if err != nil {
+		return err
+	}
+
+	treeData, err := marshalWithoutRev(treeMap)
+	if err != nil {
+		return err
+	}
+	_, err = c.PutTreeDataContext(ctx, treeName, treeData)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ERROR: error importing journey %s, %s", treeName, err.Error()))
+	}
+	return nil
+}
+
+func ImportJourneyWithOptions(frt FRToken, journeyMap map[string]interface{}, opts ImportJourneyOptions) error {
+	return defaultClient(&frt).ImportJourneyWithOptions(journeyMap, opts)
+}
+
+func ImportJourneyWithOptionsContext(ctx context.Context, frt FRToken, journeyMap map[string]interface{}, opts ImportJourneyOptions) error {
+	return defaultClient(&frt).ImportJourneyWithOptionsContext(ctx, journeyMap, opts)
+}
+
+type ExportAllJourneysOptions struct {
+	// only export trees that use custom nodes, as reported by IsCustom
+	SkipOOTB bool
+}
+
+func (c *Client) ExportAllJourneys(opts ExportAllJourneysOptions) (map[string]interface{}, error) {
+	return c.ExportAllJourneysContext(context.Background(), opts)
+}
+
+func (c *Client) ExportAllJourneysContext(ctx context.Context, opts ExportAllJourneysOptions) (map[string]interface{}, error) {
+	var exportMap = make(map[string](interface{}))
+	var treesMap = make(map[string](interface{}))
+	var scriptsMap = make(map[string](interface{}))
+	var emailTemplatesMap = make(map[string](interface{}))
+
+	exportMap["origin"] = GetOrigin(c.frt.tenant, c.frt.realm)
+
+	trees, err := c.queryTrees(ctx)
+	if err != nil {
+		return exportMap, err
+	}
+	names := make([]string, 0, len(trees))
+	for _, treeMap := range trees {
+		// a tree whose nodes can't be checked counts as custom, its export reports the problem
+		if opts.SkipOOTB && !c.IsCustomContext(ctx, treeMap) {
+			continue
+		}
+		name, _ := treeMap["_id"].(string)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		journeyMap, err := c.GetJourneyDataContext(ctx, name)
+		if err != nil {
+			return exportMap, err
+		}
+		// scripts and email templates are shared between trees, keep one copy of each
+		if scripts, exists := journeyMap["scripts"].(map[string]interface{}); exists {
+			for scriptId, script := range scripts {
+				scriptsMap[scriptId] = script
+			}
+		}
+		if emailTemplates, exists := journeyMap["emailTemplates"].(map[string]interface{}); exists {
+			for templateId, template := range emailTemplates {
+				emailTemplatesMap[templateId] = template
+			}
+		}
+		treeMap := make(map[string](interface{}))
+		treeMap["tree"] = journeyMap["tree"]
+		treeMap["nodes"] = journeyMap["nodes"]
+		if journeyMap["innernodes"] != nil {
+			treeMap["innernodes"] = journeyMap["innernodes"]
+		}
+		treesMap[name] = treeMap
+	}
+
+	exportMap["trees"] = treesMap
+	exportMap["scripts"] = scriptsMap
+	exportMap["emailTemplates"] = emailTemplatesMap
+	return exportMap, nil
+}
+
+func ExportAllJourneys(frt FRToken, opts ExportAllJourneysOptions) (map[string]interface{}, error) {
+	return defaultClient(&frt).ExportAllJourneys(opts)
+}
+
+func ExportAllJourneysContext(ctx context.Context, frt FRToken, opts ExportAllJourneysOptions) (map[string]interface{}, error) {
+	return defaultClient(&frt).ExportAllJourneysContext(ctx, opts)
+}
+
+// GetInnerTrees returns the names of the trees called by InnerTreeEvaluatorNodes in the journey.
+func GetInnerTrees(journeyMap map[string]interface{}) []string {
+	innerTrees := make([]string, 0)
+	seen := make(map[string]bool)
+	nodesMap, _ := journeyMap["nodes"].(map[string]interface{})
+	for _, node := range nodesMap {
+		nodeMap, ok := node.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		nodeType, err := getNodeType(nodeMap)
+		if err != nil || nodeType != "InnerTreeEvaluatorNode" {
+			continue
+		}
+		innerTree, ok := nodeMap["tree"].(string)
+		if ok && !seen[innerTree] {
+			seen[innerTree] = true
+			innerTrees = append(innerTrees, innerTree)
+		}
+	}
+	sort.Strings(innerTrees)
+	return innerTrees
+}
+
+// SortJourneysByDependency orders the trees of an ExportAllJourneys document so that
+// inner trees come before the trees calling them. Trees that are referenced but not
+// part of the export are assumed to exist already.
+func SortJourneysByDependency(treesMap map[string]interface{}) ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	names := make([]string, 0, len(treesMap))
+	for name := range treesMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	state := make(map[string]int)
+	order := make([]string, 0, len(names))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.New(fmt.Sprintf("ERROR: inner tree cycle detected: %s -> %s", strings.Join(path, " -> "), name))
+		}
+		state[name] = visiting
+		journeyMap, _ := treesMap[name].(map[string]interface{})
+		for _, innerTree := range GetInnerTrees(journeyMap) {
+			if _, exists := treesMap[innerTree]; !exists {
+				continue
+			}
+			err := visit(innerTree, append(path, name))
+			if err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		err := visit(name, []string{})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func (c *Client) ImportAllJourneys(exportMap map[string]interface{}, opts ImportJourneyOptions) error {
+	return c.ImportAllJourneysContext(context.Background(), exportMap, opts)
+}
+
+func (c *Client) ImportAllJourneysContext(ctx context.Context, exportMap map[string]interface{}, opts ImportJourneyOptions) error {
+	treesMap, ok := exportMap["trees"].(map[string]interface{})
+	if !ok {
+		return errors.New("ERROR: export has no trees")
+	}
+	order, err := SortJourneysByDependency(treesMap)
+	if err != nil {
+		return err
+	}
+	// all trees share the origin of the export, refuse before the shared scripts go in
+	_, err = c.originReUUID(exportMap["origin"], opts)
+	if err != nil {
+		return err
+	}
+
+	err = c.importScripts(ctx, exportMap["scripts"])
+	if err != nil {
+		return err
+	}
+	err = c.importEmailTemplates(ctx, exportMap["emailTemplates"])
+	if err != nil {
+		return err
+	}
+
+	for _, name := range order {
+		treeMap, ok := treesMap[name].(map[string]interface{})
+		if !ok {
+			return errors.New(fmt.Sprintf("ERROR: unexpected tree object %s", name))
+		}
+		journeyMap := make(map[string](interface{}))
+		journeyMap["origin"] = exportMap["origin"]
+		journeyMap["tree"] = treeMap["tree"]
+		journeyMap["nodes"] = treeMap["nodes"]
+		journeyMap["innernodes"] = treeMap["innernodes"]
+		err = c.importJourney(ctx, journeyMap, opts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ImportAllJourneys(frt FRToken, exportMap map[string]interface{}, opts ImportJourneyOptions) error {
+	return defaultClient(&frt).ImportAllJourneys(exportMap, opts)
+}
+
+func ImportAllJourneysContext(ctx context.Context, frt FRToken, exportMap map[string]interface{}, opts ImportJourneyOptions) error {
+	return defaultClient(&frt).ImportAllJourneysContext(ctx, exportMap, opts)
+}
+
+func (c *Client) deleteAMObject(ctx context.Context, jURL string, objectName string) error {
+	resp1, err1 := c.amRequest(ctx).
+		Delete(jURL)
+	if err1 == nil {
+		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+			return newAPIError(resp1, fmt.Sprintf("ERROR: delete %s call returned %d", objectName, resp1.StatusCode()))
+		}
+		return nil
+	} else {
+		return errors.New(fmt.Sprintf("ERROR: error deleting %s, %s\n", objectName, err1.Error()))
+	}
+}
+
+func (c *Client) DeleteNodeData(id string, nodeType string) error {
+	return c.DeleteNodeDataContext(context.Background(), id, nodeType)
+}
+
+func (c *Client) DeleteNodeDataContext(ctx context.Context, id string, nodeType string) error {
+	jURL := c.amURL(nodeURLTemplate, nodeType, id)
+	return c.deleteAMObject(ctx, jURL, "node")
+}
+
+func DeleteNodeData(frt FRToken, id string, nodeType string) error {
+	return defaultClient(&frt).DeleteNodeData(id, nodeType)
+}
+
+func DeleteNodeDataContext(ctx context.Context, frt FRToken, id string, nodeType string) error {
+	return defaultClient(&frt).DeleteNodeDataContext(ctx, id, nodeType)
+}
+
+func (c *Client) DeleteTreeData(name string) error {
+	return c.DeleteTreeDataContext(context.Background(), name)
+}
+
+func (c *Client) DeleteTreeDataContext(ctx context.Context, name string) error {
+	jURL := c.amURL(journeyURLTemplate, name)
+	return c.deleteAMObject(ctx, jURL, "tree")
+}
+
+func DeleteTreeData(frt FRToken, name string) error {
+	return defaultClient(&frt).DeleteTreeData(name)
+}
+
+func DeleteTreeDataContext(ctx context.Context, frt FRToken, name string) error {
+	return defaultClient(&frt).DeleteTreeDataContext(ctx, name)
+}
+
+func (c *Client) DeleteScriptData(id string) error {
+	return c.DeleteScriptDataContext(context.Background(), id)
+}
+
+func (c *Client) DeleteScriptDataContext(ctx context.Context, id string) error {
+	jURL := c.amURL(scriptURLTemplate, id)
+	return c.deleteAMObject(ctx, jURL, "script")
+}
+
+func DeleteScriptData(frt FRToken, id string) error {
+	return defaultClient(&frt).DeleteScriptData(id)
+}
+
+func DeleteScriptDataContext(ctx context.Context, frt FRToken, id string) error {
+	return defaultClient(&frt).DeleteScriptDataContext(ctx, id)
+}
+
+func (c *Client) DeleteEmailTemplateData(id string) error {
+	return c.DeleteEmailTemplateDataContext(context.Background(), id)
+}
+
+func (c *Client) DeleteEmailTemplateDataContext(ctx context.Context, id string) error {
+	jURL := c.idmURL(emailTemplateURLTemplate, id)
+	resp1, err1 := c.idmRequest(ctx).
+		Delete(jURL)
+	if err1 == nil {
+		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+			return newAPIError(resp1, fmt.Sprintf("ERROR: delete email template call returned %d", resp1.StatusCode()))
+		}
+		return nil
+	} else {
+		return errors.New(fmt.Sprintf("ERROR: error deleting email template, %s\n", err1.Error()))
+	}
+}
+
+func DeleteEmailTemplateData(frt FRToken, id string) error {
+	return defaultClient(&frt).DeleteEmailTemplateData(id)
+}
+
+func DeleteEmailTemplateDataContext(ctx context.Context, frt FRToken, id string) error {
+	return defaultClient(&frt).DeleteEmailTemplateDataContext(ctx, id)
+}
+
+type DeleteJourneyOptions struct {
+	// also delete scripts and email templates no other journey in the realm uses
+	DeleteDependencies bool
+}
+
+func (c *Client) DeleteJourney(name string, opts DeleteJourneyOptions) error {
+	return c.DeleteJourneyContext(context.Background(), name, opts)
+}
+
+func (c *Client) DeleteJourneyContext(ctx context.Context, name string, opts DeleteJourneyOptions) error {
+	// only the tree and the node configs are read, so a journey whose scripts or email
+	// templates are already gone can still be deleted
+	treeData, err := c.GetTreeDataContext(ctx, name)
+	if err != nil {
+		return err
+	}
+	var tree Tree
+	err = json.Unmarshal(treeData, &tree)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ERROR: fail to unmarshal tree json, %s", err.Error()))
+	}
+	nodes := make([]PageNodeChild, 0, len(tree.Nodes))
+	for nodeId, nodeRef := range tree.Nodes {
+		nodes = append(nodes, PageNodeChild{Id: nodeId, NodeType: nodeRef.NodeType})
+	}
+	scripts, emailTemplates, nodesInPages, err := c.nodeDependencies(ctx, nodes)
+	if err != nil {
+		return err
+	}
+
+	// find out what can go before anything is gone, so a failure here leaves the journey
+	// in place
+	var deleteScripts, deleteEmailTemplates []string
+	if opts.DeleteDependencies {
+		deleteScripts, deleteEmailTemplates, err = c.unusedDependencies(ctx, name, scripts, emailTemplates)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.DeleteTreeDataContext(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	// nodes can only be removed once the tree no longer references them, pages before
+	// the nodes on them
+	for _, node := range append(nodes, nodesInPages...) {
+		err = c.DeleteNodeDataContext(ctx, node.Id, node.NodeType)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, scriptId := range deleteScripts {
+		err = c.DeleteScriptDataContext(ctx, scriptId)
+		if err != nil {
+			return err
+		}
+	}
+	for _, templateId := range deleteEmailTemplates {
+		err = c.DeleteEmailTemplateDataContext(ctx, templateId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unusedDependencies returns the scripts and email templates of tree that can be deleted
+// with it: those that still exist, no other tree uses and, for scripts, aren't one of the
+// global defaults.
+func (c *Client) unusedDependencies(ctx context.Context, tree string, scripts map[string]bool, emailTemplates map[string]bool) ([]string, []string, error) {
+	usedScripts, usedEmailTemplates, err := c.usedDependencies(ctx, tree)
+	if err != nil {
+		return nil, nil, err
+	}
+	var deleteScripts, deleteEmailTemplates []string
+	for scriptId := range scripts {
+		if usedScripts[scriptId] {
+			continue
+		}
+		scriptData, err := c.GetScriptDataContext(ctx, scriptId)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		var scriptMap map[string]interface{}
+		err = json.Unmarshal(scriptData, &scriptMap)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("ERROR: fail to unmarshal script json, %s", err.Error()))
+		}
+		// the global default scripts can't be deleted
+		if isDefault, _ := scriptMap["default"].(bool); !isDefault {
+			deleteScripts = append(deleteScripts, scriptId)
+		}
+	}
+	// email templates only live in IDM
+	if c.frt.deploymentType != "Cloud" && c.frt.deploymentType != "ForgeOps" {
+		return deleteScripts, nil, nil
+	}
+	for templateId := range emailTemplates {
+		if usedEmailTemplates[templateId] {
+			continue
+		}
+		_, err := c.GetEmailTemplateDataContext(ctx, templateId)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		deleteEmailTemplates = append(deleteEmailTemplates, templateId)
+	}
+	return deleteScripts, deleteEmailTemplates, nil
+}
+
+// usedDependencies returns the scripts and email templates the trees of the realm other
+// than skipTree use.
+func (c *Client) usedDependencies(ctx context.Context, skipTree string) (map[string]bool, map[string]bool, error) {
+	trees, err := c.queryTrees(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var nodes []PageNodeChild
+	for _, treeMap := range trees {
+		if treeName, _ := treeMap["_id"].(string); treeName == skipTree {
+			continue
+		}
+		nodesMap, _ := treeMap["nodes"].(map[string]interface{})
+		for nodeId, node := range nodesMap {
+			nodeRefMap, _ := node.(map[string]interface{})
+			nodeType, _ := nodeRefMap["nodeType"].(string)
+			nodes = append(nodes, PageNodeChild{Id: nodeId, NodeType: nodeType})
+		}
+	}
+	usedScripts, usedEmailTemplates, _, err := c.nodeDependencies(ctx, nodes)
+	return usedScripts, usedEmailTemplates, err
+}
+
+// nodeDependencies returns the scripts and email templates nodes and the nodes on their
+// pages use, and the nodes on the pages. Only the nodes that can refer to them, and page
+// nodes for their children, are read.
+func (c *Client) nodeDependencies(ctx context.Context, nodes []PageNodeChild) (map[string]bool, map[string]bool, []PageNodeChild, error) {
+	scripts := make(map[string]bool)
+	emailTemplates := make(map[string]bool)
+	var nodesInPages []PageNodeChild
+	var pending []PageNodeChild
+	for _, node := range nodes {
+		if scriptUsingNodes[node.NodeType] || emailTemplateNodes[node.NodeType] || containerNodes[node.NodeType] {
+			pending = append(pending, node)
+		}
+	}
+
+	// the nodes of the trees first, then the ones on their pages
+	for len(pending) > 0 {
+		nodesData, err := c.fetchAll(ctx, len(pending), nil, func(ctx context.Context, i int) ([]byte, error) {
+			return c.GetNodeDataContext(ctx, pending[i].Id, pending[i].NodeType)
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		var next []PageNodeChild
+		for i, nodeData := range nodesData {
+			var node Node
+			err := json.Unmarshal(nodeData, &node)
+			if err != nil {
+				return nil, nil, nil, errors.New(fmt.Sprintf("ERROR: fail to unmarshal node json, %s", err.Error()))
+			}
+			if scriptId, exists := node.StringProperty("script"); exists && scriptUsingNodes[pending[i].NodeType] {
+				scripts[scriptId] = true
+			}
+			if templateId, exists := node.StringProperty("emailTemplateName"); exists && emailTemplateNodes[pending[i].NodeType] {
+				emailTemplates[templateId] = true
+			}
+			if containerNodes[pending[i].NodeType] {
+				pageNode, err := node.AsPageNode()
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				for _, child := range pageNode.Nodes {
+					nodesInPages = append(nodesInPages, child)
+					if scriptUsingNodes[child.NodeType] || emailTemplateNodes[child.NodeType] {
+						next = append(next, child)
+					}
+				}
+			}
+		}
+		pending = next
+	}
+	return scripts, emailTemplates, nodesInPages, nil
+}
+
+func DeleteJourney(frt FRToken, name string, opts DeleteJourneyOptions) error {
+	return defaultClient(&frt).DeleteJourney(name, opts)
+}
+
+func DeleteJourneyContext(ctx context.Context, frt FRToken, name string, opts DeleteJourneyOptions) error {
+	return defaultClient(&frt).DeleteJourneyContext(ctx, name, opts)
+}
@@ -0,0 +1,383 @@
+package frodolibs
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func journeyFixture(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	journeyMap := make(map[string]interface{})
+	err := json.Unmarshal([]byte(data), &journeyMap)
+	if err != nil {
+		t.Fatalf("bad fixture, %v", err)
+	}
+	return journeyMap
+}
+
+const pageJourneyFixture string = `{
+	"tree": {"_id": "Login", "entryNodeId": "page", "nodes": {
+		"page": {"nodeType": "PageNode", "connections": {"outcome": "decide"}},
+		"decide": {"nodeType": "DataStoreDecisionNode", "connections": {
+			"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0", "false": "e301438c-0bd0-429c-ab0c-66126501069a"}}}},
+	"nodes": {
+		"page": {"_id": "page", "_type": {"_id": "PageNode"}, "nodes": [
+			{"_id": "user", "nodeType": "ValidatedUsernameNode"},
+			{"_id": "pass", "nodeType": "ValidatedPasswordNode"}]},
+		"decide": {"_id": "decide", "_type": {"_id": "DataStoreDecisionNode"}}},
+	"innernodes": {
+		"user": {"_id": "user", "_type": {"_id": "ValidatedUsernameNode"}},
+		"pass": {"_id": "pass", "_type": {"_id": "ValidatedPasswordNode"}}}
+}`
+
+func TestRemapNodeIds(t *testing.T) {
+	tests := []struct {
+		name    string
+		journey string
+		// connections that must keep pointing outside the journey
+		external []string
+		wantErr  bool
+	}{
+		{"page node children", pageJourneyFixture, []string{SuccessNodeId, FailureNodeId}, false},
+		{"connection to a node outside the export", `{
+			"tree": {"_id": "T", "entryNodeId": "a", "nodes": {"a": {"nodeType": "X", "connections": {"outcome": "elsewhere"}}}},
+			"nodes": {"a": {"_id": "a", "_type": {"_id": "X"}}}}`, []string{"elsewhere"}, false},
+		{"no tree", `{"nodes": {}}`, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			original := journeyFixture(t, test.journey)
+			before, _ := json.Marshal(original)
+			remapped, err := RemapNodeIds(original)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, _ := json.Marshal(original)
+			if string(before) != string(after) {
+				t.Error("RemapNodeIds changed its input")
+			}
+
+			journey, err := JourneyFromMap(remapped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			originalJourney, _ := JourneyFromMap(original)
+			if _, exists := journey.Tree.Nodes[journey.Tree.EntryNodeId]; !exists {
+				t.Errorf("entry node %s is not in the tree", journey.Tree.EntryNodeId)
+			}
+			if len(journey.Nodes) != len(originalJourney.Nodes) || len(journey.InnerNodes) != len(originalJourney.InnerNodes) {
+				t.Errorf("node counts changed")
+			}
+			external := make(map[string]bool)
+			for _, id := range test.external {
+				external[id] = true
+			}
+			for nodeId, nodeRef := range journey.Tree.Nodes {
+				if _, exists := originalJourney.Nodes[nodeId]; exists {
+					t.Errorf("node %s kept its id", nodeId)
+				}
+				node, exists := journey.Nodes[nodeId]
+				if !exists || node.Id != nodeId {
+					t.Errorf("tree node %s has no matching node config", nodeId)
+				}
+				for outcome, target := range nodeRef.Connections {
+					if _, exists := journey.Tree.Nodes[target]; !exists && !external[target] {
+						t.Errorf("outcome %s of %s points at unknown %s", outcome, nodeId, target)
+					}
+				}
+				if !node.IsPageNode() {
+					continue
+				}
+				pageNode, _ := node.AsPageNode()
+				for _, child := range pageNode.Nodes {
+					innerNode, exists := journey.InnerNodes[child.Id]
+					if !exists || innerNode.Id != child.Id {
+						t.Errorf("page child %s has no matching inner node", child.Id)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSortJourneysByDependency(t *testing.T) {
+	// a tree whose nodes call the given inner trees
+	tree := func(innerTrees ...string) string {
+		nodes := make([]string, 0, len(innerTrees))
+		for i, innerTree := range innerTrees {
+			nodes = append(nodes, `"n`+string(rune('0'+i))+`": {"_id": "x", "_type": {"_id": "InnerTreeEvaluatorNode"}, "tree": "`+innerTree+`"}`)
+		}
+		return `{"nodes": {` + strings.Join(nodes, ",") + `}}`
+	}
+	tests := []struct {
+		name    string
+		trees   map[string]string
+		want    []string
+		wantErr string
+	}{
+		{"independent trees sorted by name", map[string]string{"B": tree(), "A": tree()}, []string{"A", "B"}, ""},
+		{"inner trees first", map[string]string{"A": tree("C"), "B": tree(), "C": tree("B")}, []string{"B", "C", "A"}, ""},
+		{"tree outside the export is ignored", map[string]string{"A": tree("Missing")}, []string{"A"}, ""},
+		{"cycle", map[string]string{"A": tree("B"), "B": tree("C"), "C": tree("A")}, nil, "A -> B -> C -> A"},
+		{"tree calling itself", map[string]string{"A": tree("A")}, nil, "A -> A"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			treesMap := make(map[string]interface{})
+			for name, data := range test.trees {
+				treesMap[name] = journeyFixture(t, data)
+			}
+			order, err := SortJourneysByDependency(treesMap)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(order, ",") != strings.Join(test.want, ",") {
+				t.Errorf("order = %v, want %v", order, test.want)
+			}
+		})
+	}
+}
+
+// originFixture is a deep export of Login from origin, calling the inner tree MFA
+// exported from innerOrigin
+func originFixture(origin string, innerOrigin string) string {
+	return `{
+	"origin": "` + origin + `",
+	"tree": {"_id": "Login", "entryNodeId": "mfa", "nodes": {
+		"mfa": {"nodeType": "InnerTreeEvaluatorNode", "connections": {"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0"}}}},
+	"nodes": {"mfa": {"_id": "mfa", "_type": {"_id": "InnerTreeEvaluatorNode"}, "tree": "MFA"}},
+	"scripts": {"s1": {"_id": "s1", "name": "shared", "script": ""}},
+	"innerTrees": {"MFA": {
+		"origin": "` + innerOrigin + `",
+		"tree": {"_id": "MFA", "entryNodeId": "otp", "nodes": {
+			"otp": {"nodeType": "OneTimePasswordCollectorDecisionNode", "connections": {"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0"}}}},
+		"nodes": {"otp": {"_id": "otp", "_type": {"_id": "OneTimePasswordCollectorDecisionNode"}}},
+		"scripts": {"s2": {"_id": "s2", "name": "inner", "script": ""}}}}
+}`
+}
+
+func TestImportJourneyOrigin(t *testing.T) {
+	srv := newRecordingServer()
+	defer srv.Close()
+	frt := FRToken{tenant: srv.URL, realm: "/"}
+	origin := GetOrigin(frt.tenant, frt.realm)
+	tests := []struct {
+		name     string
+		journey  string
+		opts     ImportJourneyOptions
+		wantErr  bool
+		wantPuts int
+	}{
+		{"same origin", originFixture(origin, origin), ImportJourneyOptions{}, false, 6},
+		{"foreign outer tree refused", originFixture("elsewhere", origin), ImportJourneyOptions{}, true, 0},
+		// the inner tree would be imported first, the refusal has to come before it
+		{"foreign inner tree refused", originFixture(origin, "elsewhere"), ImportJourneyOptions{}, true, 0},
+		{"foreign kept", originFixture(origin, "elsewhere"), ImportJourneyOptions{ForeignOriginPolicy: OriginPolicyKeep}, false, 6},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv.reset()
+			c, _ := NewClient(&frt)
+			err := c.ImportJourneyWithOptions(journeyFixture(t, test.journey), test.opts)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			puts := 0
+			for _, request := range srv.recorded() {
+				if strings.HasPrefix(request, "PUT ") {
+					puts++
+				}
+			}
+			if puts != test.wantPuts {
+				t.Errorf("%d PUT requests, want %d: %v", puts, test.wantPuts, srv.recorded())
+			}
+		})
+	}
+}
+
+func TestImportAllJourneysOrigin(t *testing.T) {
+	srv := newRecordingServer()
+	defer srv.Close()
+	frt := FRToken{tenant: srv.URL, realm: "/", deploymentType: "Cloud"}
+	exportAll := func(origin string) string {
+		return `{
+	"origin": "` + origin + `",
+	"trees": {"Login": {
+		"tree": {"_id": "Login", "entryNodeId": "check", "nodes": {
+			"check": {"nodeType": "ScriptedDecisionNode", "connections": {"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0"}}}},
+		"nodes": {"check": {"_id": "check", "_type": {"_id": "ScriptedDecisionNode"}, "script": "s1"}}}},
+	"scripts": {"s1": {"_id": "s1", "name": "checker", "script": ""}},
+	"emailTemplates": {"welcome": {"_id": "emailTemplate/welcome"}}
+}`
+	}
+	tests := []struct {
+		name     string
+		export   string
+		opts     ImportJourneyOptions
+		wantErr  bool
+		wantPuts int
+	}{
+		{"same origin", exportAll(GetOrigin(frt.tenant, frt.realm)), ImportJourneyOptions{}, false, 4},
+		{"foreign refused before the shared scripts", exportAll("elsewhere"), ImportJourneyOptions{}, true, 0},
+		{"foreign remapped", exportAll("elsewhere"), ImportJourneyOptions{ForeignOriginPolicy: OriginPolicyRemap}, false, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv.reset()
+			c, _ := NewClient(&frt)
+			err := c.ImportAllJourneys(journeyFixture(t, test.export), test.opts)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			puts := 0
+			for _, request := range srv.recorded() {
+				if strings.HasPrefix(request, "PUT ") {
+					puts++
+				}
+			}
+			if puts != test.wantPuts {
+				t.Errorf("%d PUT requests, want %d: %v", puts, test.wantPuts, srv.recorded())
+			}
+		})
+	}
+}
+
+func TestDeleteJourney(t *testing.T) {
+	const (
+		trees   = "/json/realms/root/realm-config/authentication/authenticationtrees/trees"
+		nodes   = "/json/realms/root/realm-config/authentication/authenticationtrees/nodes"
+		scripts = "/json/realms/root/scripts"
+	)
+	srv := newRecordingServer()
+	defer srv.Close()
+	// Login uses the missing script s-missing, its own script s-own, the default script
+	// s-default and s-shared, which Other uses as well
+	srv.objects = map[string]string{
+		trees + "/Login": `{"_id": "Login", "entryNodeId": "page", "nodes": {
+			"page": {"nodeType": "PageNode", "connections": {"outcome": "check"}},
+			"check": {"nodeType": "ScriptedDecisionNode", "connections": {"true": "own"}},
+			"own": {"nodeType": "ScriptedDecisionNode", "connections": {"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0"}}}}`,
+		trees: `{"result": [
+			{"_id": "Login", "nodes": {"check": {"nodeType": "ScriptedDecisionNode"}}},
+			{"_id": "Other", "nodes": {"o1": {"nodeType": "ClientScriptNode"}}}]}`,
+		nodes + "/PageNode/page": `{"_id": "page", "_type": {"_id": "PageNode"}, "nodes": [
+			{"_id": "def", "nodeType": "ScriptedDecisionNode"},
+			{"_id": "shared", "nodeType": "ScriptedDecisionNode"},
+			{"_id": "mail", "nodeType": "EmailSuspendNode"}]}`,
+		nodes + "/ScriptedDecisionNode/check":   `{"_id": "check", "_type": {"_id": "ScriptedDecisionNode"}, "script": "s-missing"}`,
+		nodes + "/ScriptedDecisionNode/own":     `{"_id": "own", "_type": {"_id": "ScriptedDecisionNode"}, "script": "s-own"}`,
+		nodes + "/ScriptedDecisionNode/def":     `{"_id": "def", "_type": {"_id": "ScriptedDecisionNode"}, "script": "s-default"}`,
+		nodes + "/ScriptedDecisionNode/shared":  `{"_id": "shared", "_type": {"_id": "ScriptedDecisionNode"}, "script": "s-shared"}`,
+		nodes + "/EmailSuspendNode/mail":        `{"_id": "mail", "_type": {"_id": "EmailSuspendNode"}, "emailTemplateName": "welcome"}`,
+		nodes + "/ClientScriptNode/o1":          `{"_id": "o1", "_type": {"_id": "ClientScriptNode"}, "script": "s-shared"}`,
+		scripts + "/s-own":                      `{"_id": "s-own", "default": false}`,
+		scripts + "/s-default":                  `{"_id": "s-default", "default": true}`,
+		scripts + "/s-shared":                   `{"_id": "s-shared", "default": false}`,
+		"/openidm/config/emailTemplate/welcome": `{"_id": "emailTemplate/welcome"}`,
+	}
+	deletedNodes := []string{
+		"DELETE " + nodes + "/EmailSuspendNode/mail",
+		"DELETE " + nodes + "/PageNode/page",
+		"DELETE " + nodes + "/ScriptedDecisionNode/check",
+		"DELETE " + nodes + "/ScriptedDecisionNode/def",
+		"DELETE " + nodes + "/ScriptedDecisionNode/own",
+		"DELETE " + nodes + "/ScriptedDecisionNode/shared",
+	}
+	tests := []struct {
+		name string
+		opts DeleteJourneyOptions
+		// the tree is always deleted first
+		want []string
+	}{
+		{"tree and nodes", DeleteJourneyOptions{}, deletedNodes},
+		{"unused dependencies", DeleteJourneyOptions{DeleteDependencies: true}, append([]string{
+			"DELETE /openidm/config/emailTemplate/welcome",
+			"DELETE " + scripts + "/s-own",
+		}, deletedNodes...)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv.reset()
+			c, _ := NewClient(&FRToken{tenant: srv.URL, realm: "/", deploymentType: "Cloud"})
+			err := c.DeleteJourney("Login", test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var deleted []string
+			for _, request := range srv.recorded() {
+				if strings.HasPrefix(request, "DELETE ") {
+					deleted = append(deleted, request)
+				}
+			}
+			if len(deleted) == 0 || deleted[0] != "DELETE "+trees+"/Login" {
+				t.Fatalf("deleted %v, want the tree first", deleted)
+			}
+			deleted = deleted[1:]
+			sort.Strings(deleted)
+			sort.Strings(test.want)
+			if strings.Join(deleted, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("deleted\n%s\nwant\n%s", strings.Join(deleted, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestListJourneys(t *testing.T) {
+	srv := newRecordingServer()
+	defer srv.Close()
+	// the page node of Broken is gone, so it can't be checked
+	srv.objects = map[string]string{
+		"/json/realms/root/realm-config/authentication/authenticationtrees/trees": `{"result": [
+			{"_id": "Plain", "nodes": {"d1": {"nodeType": "DataStoreDecisionNode"}}},
+			{"_id": "Custom", "nodes": {"c1": {"nodeType": "MyCustomNode"}}},
+			{"_id": "Broken", "nodes": {"p1": {"nodeType": "PageNode"}}}]}`,
+	}
+	want := map[string]bool{"Plain": false, "Custom": true, "Broken": true}
+	c, _ := NewClient(&FRToken{tenant: srv.URL, realm: "/", version: "7.1.0"})
+	report := &ExportReport{}
+	tests := []struct {
+		name   string
+		list   func() (map[string]bool, error)
+		report *ExportReport
+	}{
+		{"ListJourneys", c.ListJourneys, nil},
+		{"ListJourneysWithReport", func() (map[string]bool, error) { return c.ListJourneysWithReport(report) }, report},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list, err := test.list()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, custom := range want {
+				if list[name] != custom {
+					t.Errorf("%s custom = %v, want %v", name, list[name], custom)
+				}
+			}
+			if len(list) != len(want) {
+				t.Errorf("listed %v, want %v", list, want)
+			}
+			if test.report == nil {
+				return
+			}
+			if len(test.report.Failures) != 1 || test.report.Failures[0].Id != "Broken" || !errors.Is(test.report.Failures[0], ErrNotFound) {
+				t.Errorf("report failures = %v, want the not found tree Broken", test.report.Failures)
+			}
+		})
+	}
+}
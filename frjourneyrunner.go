@@ -0,0 +1,179 @@
+package frodolibs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const authenticateURLTemplate string = "%s/json%s/authenticate"
+
+// steps a journey may take before the runner gives up
+const defaultMaxJourneySteps int = 50
+
+// JourneyResult is what a journey run went through. Steps holds every page of callbacks
+// with the answers that were sent. A journey that ends in the failure node has no error,
+// Failure then holds what AM returned.
+type JourneyResult struct {
+	Steps      []AuthStep
+	TokenId    string
+	SuccessURL string
+	Realm      string
+	Failure    *APIError
+}
+
+func (r *JourneyResult) Succeeded() bool {
+	return r.TokenId != "" || r.SuccessURL != ""
+}
+
+// JourneyRunner goes through a journey as an end user would, answering the callbacks
+// with Handlers, to test journeys end to end. It doesn't use the admin session.
+type JourneyRunner struct {
+	// defaults to the root realm
+	Realm    string
+	Journey  string
+	Handlers []CallbackHandler
+	// defaults to 50
+	MaxSteps int
+
+	client *Client
+}
+
+func NewJourneyRunner(c *Client, realm string, journey string, handlers ...CallbackHandler) *JourneyRunner {
+	return &JourneyRunner{Realm: realm, Journey: journey, Handlers: handlers, client: c}
+}
+
+func (r *JourneyRunner) Run() (*JourneyResult, error) {
+	return r.RunContext(context.Background())
+}
+
+func (r *JourneyRunner) RunContext(ctx context.Context) (*JourneyResult, error) {
+	c := r.client
+	realm := r.Realm
+	if realm == "" {
+		realm = "/"
+	}
+	authURL := fmt.Sprintf(authenticateURLTemplate, c.frt.tenant, GetRealmUrl(realm))
+	maxSteps := r.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = defaultMaxJourneySteps
+	}
+
+	result := &JourneyResult{}
+	payload := []byte("{}")
+	var cookies []*http.Cookie
+	for step := 0; ; step++ {
+		resp1, err1 := c.request().
+			SetContext(ctx).
+			SetCookies(cookies).
+			SetHeader("Content-Type", "application/json").
+			SetHeader("Accept-API-Version", apiVersion).
+			SetQueryParams(map[string]string{
+				"authIndexType":  "service",
+				"authIndexValue": r.Journey,
+			}).
+			SetBody(payload).
+			Post(authURL)
+		if err1 != nil {
+			return result, errors.New(fmt.Sprintf("ERROR: authenticate call for journey %s failed, %s", r.Journey, err1.Error()))
+		}
+		if resp1.StatusCode() == http.StatusUnauthorized {
+			// the journey reached its failure node, or AM dropped it after a wrong answer
+			result.Failure = newAPIError(resp1, fmt.Sprintf("ERROR: journey %s failed, %s", r.Journey, resp1.String()))
+			return result, nil
+		}
+		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+			return result, newAPIError(resp1, fmt.Sprintf("ERROR: authenticate call for journey %s returned %d", r.Journey, resp1.StatusCode()))
+		}
+
+		var outcome struct {
+			TokenId    string `json:"tokenId"`
+			SuccessURL string `json:"successUrl"`
+			Realm      string `json:"realm"`
+			AuthStep
+		}
+		err := json.Unmarshal(resp1.Body(), &outcome)
+		if err != nil {
+			return result, errors.New(fmt.Sprintf("ERROR: fail to unmarshal authenticate response json, %s", err.Error()))
+		}
+		if outcome.TokenId != "" || outcome.SuccessURL != "" || len(outcome.Callbacks) == 0 {
+			// a journey can end in success without a session when the tree creates none
+			result.TokenId = outcome.TokenId
+			result.SuccessURL = outcome.SuccessURL
+			result.Realm = outcome.Realm
+			return result, nil
+		}
+		if step == maxSteps {
+			return result, errors.New(fmt.Sprintf("ERROR: journey %s didn't end after %d steps\nlikely cause: a callback no handler answers", r.Journey, maxSteps))
+		}
+
+		authStep := outcome.AuthStep
+		unanswered, err := handleCallbacks(&authStep, r.Handlers)
+		result.Steps = append(result.Steps, authStep)
+		if err != nil {
+			return result, err
+		}
+		if len(unanswered) > 0 {
+			return result, errors.New(fmt.Sprintf("ERROR: no handler answers the %s in step %d of journey %s", strings.Join(unanswered, ", "), step+1, r.Journey))
+		}
+		payload, err = json.Marshal(authStep)
+		if err != nil {
+			return result, errors.New(fmt.Sprintf("ERROR: fail to marshal json, %s", err.Error()))
+		}
+		cookies = mergeCookies(cookies, resp1.Cookies())
+	}
+}
+
+// mergeCookies adds the cookies set by a response to those sent so far, newer values win.
+func mergeCookies(cookies []*http.Cookie, set []*http.Cookie) []*http.Cookie {
+	merged := make([]*http.Cookie, 0, len(cookies)+len(set))
+	for _, cookie := range cookies {
+		replaced := false
+		for _, newCookie := range set {
+			if newCookie.Name == cookie.Name {
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, cookie)
+		}
+	}
+	return append(merged, set...)
+}
+
+// AnswerHandler answers callbacks by type with scripted values. Every callback of a type
+// takes the next answer for that type, the last answer is reused once they run out.
+type AnswerHandler struct {
+	answers map[string][]interface{}
+	used    map[string]int
+}
+
+// NewAnswerHandler scripts answers keyed by callback type, e.g.
+// {"NameCallback": {"bob"}, "PasswordCallback": {"wrong", "right"}}.
+func NewAnswerHandler(answers map[string][]interface{}) *AnswerHandler {
+	return &AnswerHandler{answers: answers, used: make(map[string]int)}
+}
+
+func (h *AnswerHandler) HandleCallback(cb *Callback) (bool, error) {
+	answers := h.answers[cb.Type]
+	if len(answers) == 0 {
+		return false, nil
+	}
+	index := h.used[cb.Type]
+	if index >= len(answers) {
+		index = len(answers) - 1
+	}
+	h.used[cb.Type]++
+	return true, cb.SetInput(answers[index])
+}
+
+func RunJourney(frt FRToken, realm string, journey string, handlers ...CallbackHandler) (*JourneyResult, error) {
+	return NewJourneyRunner(defaultClient(&frt), realm, journey, handlers...).Run()
+}
+
+func RunJourneyContext(ctx context.Context, frt FRToken, realm string, journey string, handlers ...CallbackHandler) (*JourneyResult, error) {
+	return NewJourneyRunner(defaultClient(&frt), realm, journey, handlers...).RunContext(ctx)
+}
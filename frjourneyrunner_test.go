@@ -0,0 +1,34 @@
+package frodolibs
+
+import "testing"
+
+func TestJourneyRunnerRealm(t *testing.T) {
+	tests := []struct {
+		realm    string
+		wantPath string
+	}{
+		{"", "/json/realms/root/authenticate"},
+		{"/", "/json/realms/root/authenticate"},
+		{"alpha", "/json/realms/root/realms/alpha/authenticate"},
+		{"/alpha", "/json/realms/root/realms/alpha/authenticate"},
+	}
+	for _, test := range tests {
+		t.Run("realm "+test.realm, func(t *testing.T) {
+			srv := newRecordingServer()
+			defer srv.Close()
+			c, _ := NewClient(&FRToken{tenant: srv.URL, realm: "/"})
+			result, err := NewJourneyRunner(c, test.realm, "Login").Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the recording server answers with no callbacks, which ends the journey
+			if len(result.Steps) != 0 {
+				t.Errorf("steps = %v, want none", result.Steps)
+			}
+			requests := srv.recorded()
+			if len(requests) != 1 || requests[0] != "POST "+test.wantPath {
+				t.Errorf("requests %q, want one POST %s", requests, test.wantPath)
+			}
+		})
+	}
+}
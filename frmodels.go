@@ -0,0 +1,556 @@
+package frodolibs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The typed models keep every field they don't know about in Extra, so
+// decoding and encoding an object gives back the same object.
+
+type NodeRef struct {
+	NodeType    string                     `json:"nodeType"`
+	DisplayName string                     `json:"displayName,omitempty"`
+	Connections map[string]string          `json:"connections"`
+	Extra       map[string]json.RawMessage `json:"-"`
+	preserved   map[string]rawField
+}
+
+type Tree struct {
+	Id          string                     `json:"_id"`
+	Rev         string                     `json:"_rev,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	EntryNodeId string                     `json:"entryNodeId"`
+	Nodes       map[string]NodeRef         `json:"nodes"`
+	Extra       map[string]json.RawMessage `json:"-"`
+	preserved   map[string]rawField
+}
+
+type NodeType struct {
+	Id         string `json:"_id"`
+	Name       string `json:"name,omitempty"`
+	Collection bool   `json:"collection"`
+}
+
+// Node holds the configuration of any node, the node type specific
+// properties are in Extra.
+type Node struct {
+	Id        string                     `json:"_id"`
+	Rev       string                     `json:"_rev,omitempty"`
+	Type      NodeType                   `json:"_type"`
+	Extra     map[string]json.RawMessage `json:"-"`
+	preserved map[string]rawField
+}
+
+type PageNodeChild struct {
+	Id          string `json:"_id"`
+	NodeType    string `json:"nodeType"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+type PageNode struct {
+	Id        string                     `json:"_id"`
+	Rev       string                     `json:"_rev,omitempty"`
+	Type      NodeType                   `json:"_type"`
+	Nodes     []PageNodeChild            `json:"nodes"`
+	Extra     map[string]json.RawMessage `json:"-"`
+	preserved map[string]rawField
+}
+
+type Script struct {
+	Id          string `json:"_id"`
+	Rev         string `json:"_rev,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// base64 encoded source
+	Script    string                     `json:"script"`
+	Language  string                     `json:"language"`
+	Context   string                     `json:"context"`
+	Extra     map[string]json.RawMessage `json:"-"`
+	preserved map[string]rawField
+}
+
+type EmailTemplate struct {
+	Id            string                     `json:"_id"`
+	Rev           string                     `json:"_rev,omitempty"`
+	Enabled       bool                       `json:"enabled"`
+	From          string                     `json:"from"`
+	DefaultLocale string                     `json:"defaultLocale,omitempty"`
+	Subject       map[string]string          `json:"subject,omitempty"`
+	Message       map[string]string          `json:"message,omitempty"`
+	Extra         map[string]json.RawMessage `json:"-"`
+	preserved     map[string]rawField
+}
+
+// Journey is the typed form of the GetJourneyData export.
+type Journey struct {
+	Origin         string                   `json:"origin"`
+	Tree           Tree                     `json:"tree"`
+	Nodes          map[string]Node          `json:"nodes"`
+	InnerNodes     map[string]Node          `json:"innernodes,omitempty"`
+	Scripts        map[string]Script        `json:"scripts"`
+	EmailTemplates map[string]EmailTemplate `json:"emailTemplates"`
+	InnerTrees     map[string]Journey       `json:"innerTrees,omitempty"`
+}
+
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// rawField remembers how a known field looked in the original JSON when encoding
+// the decoded value would not give it back, e.g. null for a string or a missing field.
+type rawField struct {
+	decoded  json.RawMessage
+	original json.RawMessage
+}
+
+func compactJSON(raw json.RawMessage) json.RawMessage {
+	if raw == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// unmarshalWithExtra decodes data into the struct v points to and returns the fields v has no place for.
+func unmarshalWithExtra(data []byte, v interface{}) (map[string]json.RawMessage, map[string]rawField, error) {
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return nil, nil, err
+	}
+	rawMap := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &rawMap)
+	if err != nil {
+		return nil, nil, err
+	}
+	decodedData, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	decodedMap := make(map[string]json.RawMessage)
+	err = json.Unmarshal(decodedData, &decodedMap)
+	if err != nil {
+		return nil, nil, err
+	}
+	var preserved map[string]rawField
+	for name := range jsonFieldNames(reflect.TypeOf(v).Elem()) {
+		original := compactJSON(rawMap[name])
+		if !bytes.Equal(original, decodedMap[name]) {
+			if preserved == nil {
+				preserved = make(map[string]rawField)
+			}
+			preserved[name] = rawField{decoded: decodedMap[name], original: original}
+		}
+		delete(rawMap, name)
+	}
+	if len(rawMap) == 0 {
+		rawMap = nil
+	}
+	return rawMap, preserved, nil
+}
+
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage, preserved map[string]rawField) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || (len(extra) == 0 && len(preserved) == 0) {
+		return data, err
+	}
+	rawMap := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &rawMap)
+	if err != nil {
+		return nil, err
+	}
+	for name, field := range preserved {
+		// only restore the original if the field was not changed since decoding
+		if bytes.Equal(rawMap[name], field.decoded) {
+			if field.original == nil {
+				delete(rawMap, name)
+			} else {
+				rawMap[name] = field.original
+			}
+		}
+	}
+	for name, value := range extra {
+		if _, known := rawMap[name]; !known {
+			rawMap[name] = value
+		}
+	}
+	return json.Marshal(rawMap)
+}
+
+func (n *NodeRef) UnmarshalJSON(data []byte) error {
+	type nodeRef NodeRef
+	var v nodeRef
+	extra, preserved, err := unmarshalWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*n = NodeRef(v)
+	n.Extra = extra
+	n.preserved = preserved
+	return nil
+}
+
+func (n NodeRef) MarshalJSON() ([]byte, error) {
+	type nodeRef NodeRef
+	return marshalWithExtra(nodeRef(n), n.Extra, n.preserved)
+}
+
+func (t *Tree) UnmarshalJSON(data []byte) error {
+	type tree Tree
+	var v tree
+	extra, preserved, err := unmarshalWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*t = Tree(v)
+	t.Extra = extra
+	t.preserved = preserved
+	return nil
+}
+
+func (t Tree) MarshalJSON() ([]byte, error) {
+	type tree Tree
+	return marshalWithExtra(tree(t), t.Extra, t.preserved)
+}
+
+func (n *Node) UnmarshalJSON(data []byte) error {
+	type node Node
+	var v node
+	extra, preserved, err := unmarshalWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*n = Node(v)
+	n.Extra = extra
+	n.preserved = preserved
+	return nil
+}
+
+func (n Node) MarshalJSON() ([]byte, error) {
+	type node Node
+	return marshalWithExtra(node(n), n.Extra, n.preserved)
+}
+
+// StringProperty returns a node type specific string property such as "script" or "tree".
+func (n Node) StringProperty(name string) (string, bool) {
+	raw, exists := n.Extra[name]
+	if !exists {
+		return "", false
+	}
+	var value string
+	err := json.Unmarshal(raw, &value)
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+func (n Node) IsPageNode() bool {
+	return containerNodes[n.Type.Id]
+}
+
+func (n Node) AsPageNode() (PageNode, error) {
+	var pageNode PageNode
+	data, err := json.Marshal(n)
+	if err != nil {
+		return pageNode, errors.New(fmt.Sprintf("ERROR: fail to marshal node json, %s", err.Error()))
+	}
+	err = json.Unmarshal(data, &pageNode)
+	if err != nil {
+		return pageNode, errors.New(fmt.Sprintf("ERROR: node %s is not a page node, %s", n.Id, err.Error()))
+	}
+	return pageNode, nil
+}
+
+func (p *PageNode) UnmarshalJSON(data []byte) error {
+	type pageNode PageNode
+	var v pageNode
+	extra, preserved, err := unmarshalWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*p = PageNode(v)
+	p.Extra = extra
+	p.preserved = preserved
+	return nil
+}
+
+func (p PageNode) MarshalJSON() ([]byte, error) {
+	type pageNode PageNode
+	return marshalWithExtra(pageNode(p), p.Extra, p.preserved)
+}
+
+func (s *Script) UnmarshalJSON(data []byte) error {
+	type script Script
+	var v script
+	extra, preserved, err := unmarshalWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*s = Script(v)
+	s.Extra = extra
+	s.preserved = preserved
+	return nil
+}
+
+func (s Script) MarshalJSON() ([]byte, error) {
+	type script Script
+	return marshalWithExtra(script(s), s.Extra, s.preserved)
+}
+
+func (s Script) Source() (string, error) {
+	source, err := base64.StdEncoding.DecodeString(s.Script)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("ERROR: fail to decode script %s, %s", s.Id, err.Error()))
+	}
+	return string(source), nil
+}
+
+func (s *Script) SetSource(source string) {
+	s.Script = base64.StdEncoding.EncodeToString([]byte(source))
+}
+
+func (e *EmailTemplate) UnmarshalJSON(data []byte) error {
+	type emailTemplate EmailTemplate
+	var v emailTemplate
+	extra, preserved, err := unmarshalWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*e = EmailTemplate(v)
+	e.Extra = extra
+	e.preserved = preserved
+	return nil
+}
+
+func (e EmailTemplate) MarshalJSON() ([]byte, error) {
+	type emailTemplate EmailTemplate
+	return marshalWithExtra(emailTemplate(e), e.Extra, e.preserved)
+}
+
+// convertJSON moves data between the typed models and the map[string]interface{} form.
+func convertJSON(from interface{}, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ERROR: fail to marshal json, %s", err.Error()))
+	}
+	err = json.Unmarshal(data, to)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err.Error()))
+	}
+	return nil
+}
+
+func JourneyFromMap(journeyMap map[string]interface{}) (Journey, error) {
+	var journey Journey
+	err := convertJSON(journeyMap, &journey)
+	return journey, err
+}
+
+func (j Journey) ToMap() (map[string]interface{}, error) {
+	journeyMap := make(map[string](interface{}))
+	err := convertJSON(j, &journeyMap)
+	return journeyMap, err
+}
+
+func (c *Client) GetTree(name string) (Tree, error) {
+	return c.GetTreeContext(context.Background(), name)
+}
+
+func (c *Client) GetTreeContext(ctx context.Context, name string) (Tree, error) {
+	var tree Tree
+	treeData, err := c.GetTreeDataContext(ctx, name)
+	if err != nil {
+		return tree, err
+	}
+	err = json.Unmarshal(treeData, &tree)
+	if err != nil {
+		return tree, errors.New(fmt.Sprintf("ERROR: fail to unmarshal tree json, %s", err.Error()))
+	}
+	return tree, nil
+}
+
+func GetTree(frt FRToken, name string) (Tree, error) {
+	return defaultClient(&frt).GetTree(name)
+}
+
+func GetTreeContext(ctx context.Context, frt FRToken, name string) (Tree, error) {
+	return defaultClient(&frt).GetTreeContext(ctx, name)
+}
+
+func (c *Client) GetNode(id string, nodeType string) (Node, error) {
+	return c.GetNodeContext(context.Background(), id, nodeType)
+}
+
+func (c *Client) GetNodeContext(ctx context.Context, id string, nodeType string) (Node, error) {
+	var node Node
+	nodeData, err := c.GetNodeDataContext(ctx, id, nodeType)
+	if err != nil {
+		return node, err
+	}
+	err = json.Unmarshal(nodeData, &node)
+	if err != nil {
+		return node, errors.New(fmt.Sprintf("ERROR: fail to unmarshal node json, %s", err.Error()))
+	}
+	return node, nil
+}
+
+func GetNode(frt FRToken, id string, nodeType string) (Node, error) {
+	return defaultClient(&frt).GetNode(id, nodeType)
+}
+
+func GetNodeContext(ctx context.Context, frt FRToken, id string, nodeType string) (Node, error) {
+	return defaultClient(&frt).GetNodeContext(ctx, id, nodeType)
+}
+
+func (c *Client) GetPageNode(id string, nodeType string) (PageNode, error) {
+	return c.GetPageNodeContext(context.Background(), id, nodeType)
+}
+
+func (c *Client) GetPageNodeContext(ctx context.Context, id string, nodeType string) (PageNode, error) {
+	var pageNode PageNode
+	nodeData, err := c.GetNodeDataContext(ctx, id, nodeType)
+	if err != nil {
+		return pageNode, err
+	}
+	err = json.Unmarshal(nodeData, &pageNode)
+	if err != nil {
+		return pageNode, errors.New(fmt.Sprintf("ERROR: fail to unmarshal page node json, %s", err.Error()))
+	}
+	return pageNode, nil
+}
+
+func GetPageNode(frt FRToken, id string, nodeType string) (PageNode, error) {
+	return defaultClient(&frt).GetPageNode(id, nodeType)
+}
+
+func GetPageNodeContext(ctx context.Context, frt FRToken, id string, nodeType string) (PageNode, error) {
+	return defaultClient(&frt).GetPageNodeContext(ctx, id, nodeType)
+}
+
+func (c *Client) GetScript(id string) (Script, error) {
+	return c.GetScriptContext(context.Background(), id)
+}
+
+func (c *Client) GetScriptContext(ctx context.Context, id string) (Script, error) {
+	var script Script
+	scriptData, err := c.GetScriptDataContext(ctx, id)
+	if err != nil {
+		return script, err
+	}
+	err = json.Unmarshal(scriptData, &script)
+	if err != nil {
+		return script, errors.New(fmt.Sprintf("ERROR: fail to unmarshal script json, %s", err.Error()))
+	}
+	return script, nil
+}
+
+func GetScript(frt FRToken, id string) (Script, error) {
+	return defaultClient(&frt).GetScript(id)
+}
+
+func GetScriptContext(ctx context.Context, frt FRToken, id string) (Script, error) {
+	return defaultClient(&frt).GetScriptContext(ctx, id)
+}
+
+func (c *Client) GetEmailTemplate(id string) (EmailTemplate, error) {
+	return c.GetEmailTemplateContext(context.Background(), id)
+}
+
+func (c *Client) GetEmailTemplateContext(ctx context.Context, id string) (EmailTemplate, error) {
+	var emailTemplate EmailTemplate
+	templateData, err := c.GetEmailTemplateDataContext(ctx, id)
+	if err != nil {
+		return emailTemplate, err
+	}
+	err = json.Unmarshal(templateData, &emailTemplate)
+	if err != nil {
+		return emailTemplate, errors.New(fmt.Sprintf("ERROR: fail to unmarshal email template json, %s", err.Error()))
+	}
+	return emailTemplate, nil
+}
+
+func GetEmailTemplate(frt FRToken, id string) (EmailTemplate, error) {
+	return defaultClient(&frt).GetEmailTemplate(id)
+}
+
+func GetEmailTemplateContext(ctx context.Context, frt FRToken, id string) (EmailTemplate, error) {
+	return defaultClient(&frt).GetEmailTemplateContext(ctx, id)
+}
+
+func (c *Client) GetJourney(name string) (Journey, error) {
+	return c.GetJourneyContext(context.Background(), name)
+}
+
+func (c *Client) GetJourneyContext(ctx context.Context, name string) (Journey, error) {
+	journeyMap, err := c.GetJourneyDataContext(ctx, name)
+	if err != nil {
+		return Journey{}, err
+	}
+	return JourneyFromMap(journeyMap)
+}
+
+func GetJourney(frt FRToken, name string) (Journey, error) {
+	return defaultClient(&frt).GetJourney(name)
+}
+
+func GetJourneyContext(ctx context.Context, frt FRToken, name string) (Journey, error) {
+	return defaultClient(&frt).GetJourneyContext(ctx, name)
+}
+
+func (c *Client) GetJourneyWithOptions(name string, opts ExportJourneyOptions) (Journey, error) {
+	return c.GetJourneyWithOptionsContext(context.Background(), name, opts)
+}
+
+func (c *Client) GetJourneyWithOptionsContext(ctx context.Context, name string, opts ExportJourneyOptions) (Journey, error) {
+	journeyMap, err := c.GetJourneyDataWithOptionsContext(ctx, name, opts)
+	if err != nil {
+		return Journey{}, err
+	}
+	return JourneyFromMap(journeyMap)
+}
+
+func GetJourneyWithOptions(frt FRToken, name string, opts ExportJourneyOptions) (Journey, error) {
+	return defaultClient(&frt).GetJourneyWithOptions(name, opts)
+}
+
+func GetJourneyWithOptionsContext(ctx context.Context, frt FRToken, name string, opts ExportJourneyOptions) (Journey, error) {
+	return defaultClient(&frt).GetJourneyWithOptionsContext(ctx, name, opts)
+}
+
+func (c *Client) ImportJourneyTyped(journey Journey, opts ImportJourneyOptions) error {
+	return c.ImportJourneyTypedContext(context.Background(), journey, opts)
+}
+
+func (c *Client) ImportJourneyTypedContext(ctx context.Context, journey Journey, opts ImportJourneyOptions) error {
+	journeyMap, err := journey.ToMap()
+	if err != nil {
+		return err
+	}
+	return c.ImportJourneyWithOptionsContext(ctx, journeyMap, opts)
+}
+
+func ImportJourneyTyped(frt FRToken, journey Journey, opts ImportJourneyOptions) error {
+	return defaultClient(&frt).ImportJourneyTyped(journey, opts)
+}
+
+func ImportJourneyTypedContext(ctx context.Context, frt FRToken, journey Journey, opts ImportJourneyOptions) error {
+	return defaultClient(&frt).ImportJourneyTypedContext(ctx, journey, opts)
+}
@@ -0,0 +1,361 @@
+package frodolibs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const nodeTypesURLTemplate string = "%s/json%s/realm-config/authentication/authenticationtrees/nodes?_action=getAllTypes"
+const queryAllNodesURLTemplate string = "%s/json%s/realm-config/authentication/authenticationtrees/nodes/%s?_queryFilter=true"
+const queryAllScriptsURLTemplate string = "%s/json%s/scripts?_queryFilter=true"
+const queryAllEmailTemplatesURLTemplate string = "%s/openidm/config?_queryFilter=_id%%20sw%%20%%22emailTemplate%%22"
+
+// only scripts used by tree nodes are considered, other contexts (OAuth2, policy, ...) are never orphans
+var journeyScriptContexts = map[string]bool{
+	"AUTHENTICATION_TREE_DECISION_NODE": true,
+	"CONFIG_PROVIDER_NODE":              true,
+}
+
+// nodes whose "script" property refers to a script of one of the journeyScriptContexts
+var scriptUsingNodes = map[string]bool{
+	"ScriptedDecisionNode": true,
+	"ClientScriptNode":     true,
+	"CustomScriptNode":     true,
+	"ConfigProviderNode":   true,
+}
+
+type OrphanedNode struct {
+	Id       string
+	NodeType string
+}
+
+type OrphanedScript struct {
+	Id   string
+	Name string
+}
+
+type Orphans struct {
+	Nodes   []OrphanedNode
+	Scripts []OrphanedScript
+	// email templates no node uses, IDM also sends some of them outside of journeys (welcome,
+	// resetPassword, ...) so PruneOrphans only deletes them when asked to
+	EmailTemplates []string
+}
+
+type PruneOrphansOptions struct {
+	// also delete Orphans.EmailTemplates
+	EmailTemplates bool
+}
+
+func (c *Client) queryAMResults(ctx context.Context, method string, jURL string, objectName string) ([]interface{}, error) {
+	resp1, err1 := c.amRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		Execute(method, jURL)
+	if err1 == nil {
+		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+			return nil, newAPIError(resp1, fmt.Sprintf("ERROR: query %s call returned %d", objectName, resp1.StatusCode()))
+		}
+		return resultsFromBody(resp1.Body())
+	} else {
+		return nil, errors.New(fmt.Sprintf("ERROR: error querying %s, %s\n", objectName, err1.Error()))
+	}
+}
+
+func resultsFromBody(body []byte) ([]interface{}, error) {
+	jsonMap := make(map[string](interface{}))
+	err := json.Unmarshal(body, &jsonMap)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err.Error()))
+	}
+	results, _ := jsonMap["result"].([]interface{})
+	return results, nil
+}
+
+func (c *Client) GetNodeTypes() ([]string, error) {
+	return c.GetNodeTypesContext(context.Background())
+}
+
+func (c *Client) GetNodeTypesContext(ctx context.Context) ([]string, error) {
+	jURL := c.amURL(nodeTypesURLTemplate)
+	results, err := c.queryAMResults(ctx, resty.MethodPost, jURL, "node types")
+	if err != nil {
+		return nil, err
+	}
+	nodeTypes := make([]string, 0, len(results))
+	for index := range results {
+		resultMap, ok := results[index].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if nodeType, ok := resultMap["_id"].(string); ok {
+			nodeTypes = append(nodeTypes, nodeType)
+		}
+	}
+	sort.Strings(nodeTypes)
+	return nodeTypes, nil
+}
+
+func GetNodeTypes(frt FRToken) ([]string, error) {
+	return defaultClient(&frt).GetNodeTypes()
+}
+
+func GetNodeTypesContext(ctx context.Context, frt FRToken) ([]string, error) {
+	return defaultClient(&frt).GetNodeTypesContext(ctx)
+}
+
+func (c *Client) ListNodes(nodeType string) ([]interface{}, error) {
+	return c.ListNodesContext(context.Background(), nodeType)
+}
+
+func (c *Client) ListNodesContext(ctx context.Context, nodeType string) ([]interface{}, error) {
+	jURL := c.amURL(queryAllNodesURLTemplate, nodeType)
+	return c.queryAMResults(ctx, resty.MethodGet, jURL, "nodes")
+}
+
+func ListNodes(frt FRToken, nodeType string) ([]interface{}, error) {
+	return defaultClient(&frt).ListNodes(nodeType)
+}
+
+func ListNodesContext(ctx context.Context, frt FRToken, nodeType string) ([]interface{}, error) {
+	return defaultClient(&frt).ListNodesContext(ctx, nodeType)
+}
+
+func (c *Client) ListScripts() ([]interface{}, error) {
+	return c.ListScriptsContext(context.Background())
+}
+
+func (c *Client) ListScriptsContext(ctx context.Context) ([]interface{}, error) {
+	jURL := c.amURL(queryAllScriptsURLTemplate)
+	return c.queryAMResults(ctx, resty.MethodGet, jURL, "scripts")
+}
+
+func ListScripts(frt FRToken) ([]interface{}, error) {
+	return defaultClient(&frt).ListScripts()
+}
+
+func ListScriptsContext(ctx context.Context, frt FRToken) ([]interface{}, error) {
+	return defaultClient(&frt).ListScriptsContext(ctx)
+}
+
+func (c *Client) ListEmailTemplates() ([]interface{}, error) {
+	return c.ListEmailTemplatesContext(context.Background())
+}
+
+func (c *Client) ListEmailTemplatesContext(ctx context.Context) ([]interface{}, error) {
+	jURL := c.idmURL(queryAllEmailTemplatesURLTemplate)
+	resp1, err1 := c.idmRequest(ctx).
+		Get(jURL)
+	if err1 == nil {
+		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+			return nil, newAPIError(resp1, fmt.Sprintf("ERROR: query email templates call returned %d", resp1.StatusCode()))
+		}
+		return resultsFromBody(resp1.Body())
+	} else {
+		return nil, errors.New(fmt.Sprintf("ERROR: error querying email templates, %s\n", err1.Error()))
+	}
+}
+
+func ListEmailTemplates(frt FRToken) ([]interface{}, error) {
+	return defaultClient(&frt).ListEmailTemplates()
+}
+
+func ListEmailTemplatesContext(ctx context.Context, frt FRToken) ([]interface{}, error) {
+	return defaultClient(&frt).ListEmailTemplatesContext(ctx)
+}
+
+func (c *Client) FindOrphans() (Orphans, error) {
+	return c.FindOrphansContext(context.Background())
+}
+
+func (c *Client) FindOrphansContext(ctx context.Context) (Orphans, error) {
+	var orphans Orphans
+
+	// every node the trees of the realm point at
+	trees, err := c.queryTrees(ctx)
+	if err != nil {
+		return orphans, err
+	}
+	usedNodes := make(map[string]bool)
+	for _, treeMap := range trees {
+		nodesMap, _ := treeMap["nodes"].(map[string]interface{})
+		for nodeId := range nodesMap {
+			usedNodes[nodeId] = true
+		}
+	}
+
+	// every node instance in the realm, by type
+	nodeTypes, err := c.GetNodeTypesContext(ctx)
+	if err != nil {
+		return orphans, err
+	}
+	allNodes := make(map[string]map[string]interface{})
+	allNodeTypes := make(map[string]string)
+	for _, nodeType := range nodeTypes {
+		nodes, err := c.ListNodesContext(ctx, nodeType)
+		if err != nil {
+			return orphans, err
+		}
+		for index := range nodes {
+			nodeMap, ok := nodes[index].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			nodeId, _ := nodeMap["_id"].(string)
+			allNodes[nodeId] = nodeMap
+			allNodeTypes[nodeId] = nodeType
+		}
+	}
+
+	// children of used page nodes are used as well
+	for nodeId := range usedNodes {
+		if !containerNodes[allNodeTypes[nodeId]] {
+			continue
+		}
+		nodesInPage, _ := allNodes[nodeId]["nodes"].([]interface{})
+		for index := range nodesInPage {
+			if nodeInPageMap, ok := nodesInPage[index].(map[string]interface{}); ok {
+				if nodeIdInPage, ok := nodeInPageMap["_id"].(string); ok {
+					usedNodes[nodeIdInPage] = true
+				}
+			}
+		}
+	}
+
+	usedScripts := make(map[string]bool)
+	usedEmailTemplates := make(map[string]bool)
+	for nodeId, nodeMap := range allNodes {
+		if !usedNodes[nodeId] {
+			orphans.Nodes = append(orphans.Nodes, OrphanedNode{Id: nodeId, NodeType: allNodeTypes[nodeId]})
+			continue
+		}
+		if scriptUsingNodes[allNodeTypes[nodeId]] {
+			if scriptId, ok := nodeMap["script"].(string); ok {
+				usedScripts[scriptId] = true
+			}
+		}
+		if emailTemplateNodes[allNodeTypes[nodeId]] {
+			if templateId, ok := nodeMap["emailTemplateName"].(string); ok {
+				usedEmailTemplates[templateId] = true
+			}
+		}
+	}
+	// remove page nodes before their children
+	sort.Slice(orphans.Nodes, func(i, j int) bool {
+		iContainer := containerNodes[orphans.Nodes[i].NodeType]
+		jContainer := containerNodes[orphans.Nodes[j].NodeType]
+		if iContainer != jContainer {
+			return iContainer
+		}
+		return orphans.Nodes[i].Id < orphans.Nodes[j].Id
+	})
+
+	scripts, err := c.ListScriptsContext(ctx)
+	if err != nil {
+		return orphans, err
+	}
+	for index := range scripts {
+		scriptMap, ok := scripts[index].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		scriptId, _ := scriptMap["_id"].(string)
+		scriptContext, _ := scriptMap["context"].(string)
+		// the global default scripts show up in every realm and can't be deleted
+		isDefault, _ := scriptMap["default"].(bool)
+		if journeyScriptContexts[scriptContext] && !isDefault && !usedScripts[scriptId] {
+			scriptName, _ := scriptMap["name"].(string)
+			orphans.Scripts = append(orphans.Scripts, OrphanedScript{Id: scriptId, Name: scriptName})
+		}
+	}
+	sort.Slice(orphans.Scripts, func(i, j int) bool {
+		return orphans.Scripts[i].Name < orphans.Scripts[j].Name
+	})
+
+	if c.frt.deploymentType == "Cloud" || c.frt.deploymentType == "ForgeOps" {
+		emailTemplates, err := c.ListEmailTemplatesContext(ctx)
+		if err != nil {
+			return orphans, err
+		}
+		for index := range emailTemplates {
+			templateMap, ok := emailTemplates[index].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			configId, _ := templateMap["_id"].(string)
+			templateId := strings.TrimPrefix(configId, "emailTemplate/")
+			if !usedEmailTemplates[templateId] {
+				orphans.EmailTemplates = append(orphans.EmailTemplates, templateId)
+			}
+		}
+		sort.Strings(orphans.EmailTemplates)
+	}
+	return orphans, nil
+}
+
+func FindOrphans(frt FRToken) (Orphans, error) {
+	return defaultClient(&frt).FindOrphans()
+}
+
+func FindOrphansContext(ctx context.Context, frt FRToken) (Orphans, error) {
+	return defaultClient(&frt).FindOrphansContext(ctx)
+}
+
+// PruneOrphans deletes the orphaned nodes and scripts, but not the email templates.
+func (c *Client) PruneOrphans(orphans Orphans) error {
+	return c.PruneOrphansWithOptionsContext(context.Background(), orphans, PruneOrphansOptions{})
+}
+
+func (c *Client) PruneOrphansContext(ctx context.Context, orphans Orphans) error {
+	return c.PruneOrphansWithOptionsContext(ctx, orphans, PruneOrphansOptions{})
+}
+
+func (c *Client) PruneOrphansWithOptions(orphans Orphans, opts PruneOrphansOptions) error {
+	return c.PruneOrphansWithOptionsContext(context.Background(), orphans, opts)
+}
+
+func (c *Client) PruneOrphansWithOptionsContext(ctx context.Context, orphans Orphans, opts PruneOrphansOptions) error {
+	for _, node := range orphans.Nodes {
+		err := c.DeleteNodeDataContext(ctx, node.Id, node.NodeType)
+		if err != nil {
+			return err
+		}
+	}
+	for _, script := range orphans.Scripts {
+		err := c.DeleteScriptDataContext(ctx, script.Id)
+		if err != nil {
+			return err
+		}
+	}
+	if !opts.EmailTemplates {
+		return nil
+	}
+	for _, templateId := range orphans.EmailTemplates {
+		err := c.DeleteEmailTemplateDataContext(ctx, templateId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func PruneOrphans(frt FRToken, orphans Orphans) error {
+	return defaultClient(&frt).PruneOrphans(orphans)
+}
+
+func PruneOrphansContext(ctx context.Context, frt FRToken, orphans Orphans) error {
+	return defaultClient(&frt).PruneOrphansContext(ctx, orphans)
+}
+
+func PruneOrphansWithOptions(frt FRToken, orphans Orphans, opts PruneOrphansOptions) error {
+	return defaultClient(&frt).PruneOrphansWithOptions(orphans, opts)
+}
+
+func PruneOrphansWithOptionsContext(ctx context.Context, frt FRToken, orphans Orphans, opts PruneOrphansOptions) error {
+	return defaultClient(&frt).PruneOrphansWithOptionsContext(ctx, orphans, opts)
+}
@@ -0,0 +1,218 @@
+package frodolibs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const sessionsURLTemplate string = "%s/json%s/sessions?_action=%s"
+const sessionsApiVersion string = "resource=4.0"
+
+// tokens this close to expiring are renewed before the next call that uses them
+const tokenRefreshMargin time.Duration = time.Minute
+
+// requests made while renewing tokens carry this in their context, so they don't
+// trigger another renewal
+type skipRefreshKey struct{}
+
+// requests built by amRequest and idmRequest carry this in their context
+type adminTokensKey struct{}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type sessionInfo struct {
+	MaxIdleExpirationTime    time.Time `json:"maxIdleExpirationTime"`
+	MaxSessionExpirationTime time.Time `json:"maxSessionExpirationTime"`
+}
+
+// BearerTokenLifetime returns how long the bearer token stays valid, false when there is
+// no token or its lifetime is unknown.
+func (frt *FRToken) BearerTokenLifetime() (time.Duration, bool) {
+	if frt.bearerToken == "" || frt.bearerExpiry.IsZero() {
+		return 0, false
+	}
+	return time.Until(frt.bearerExpiry), true
+}
+
+// SessionLifetime returns how long the AM session stays valid without being used, false
+// when there is no session or its lifetime is unknown.
+func (frt *FRToken) SessionLifetime() (time.Duration, bool) {
+	if frt.tokenId == "" || frt.sessionExpiry.IsZero() {
+		return 0, false
+	}
+	return time.Until(frt.sessionExpiry), true
+}
+
+func (c *Client) setSession(tokenId string, expiry time.Time) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.frt.tokenId = tokenId
+	c.frt.sessionExpiry = expiry
+}
+
+// setBearer records the access token, its expiry and refresh token from a token endpoint
+// response.
+func (c *Client) setBearer(payload []byte) error {
+	var token tokenResponse
+	err := json.Unmarshal(payload, &token)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err.Error()))
+	}
+	if token.AccessToken == "" {
+		return errors.New("ERROR: no access_token found in response")
+	}
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.frt.bearerToken = token.AccessToken
+	c.frt.bearerExpiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		c.frt.bearerExpiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	c.frt.refreshToken = token.RefreshToken
+	return nil
+}
+
+// sessionExpiry asks AM when the current session ends, action is getSessionInfo or
+// refresh, which also resets the idle timeout.
+func (c *Client) sessionExpiry(ctx context.Context, action string) (time.Time, error) {
+	resp1, err1 := c.amRequest(context.WithValue(ctx, skipRefreshKey{}, true)).
+		SetHeader("Accept-API-Version", sessionsApiVersion).
+		SetHeader("Content-Type", "application/json").
+		SetBody("{}").
+		Post(fmt.Sprintf(sessionsURLTemplate, c.frt.tenant, GetRealmUrl("/"), action))
+	if err1 != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("ERROR: session %s call failed, %s", action, err1.Error()))
+	}
+	if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+		return time.Time{}, newAPIError(resp1, fmt.Sprintf("ERROR: session %s call returned %d", action, resp1.StatusCode()))
+	}
+	var info sessionInfo
+	err := json.Unmarshal(resp1.Body(), &info)
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("ERROR: fail to unmarshal session info json, %s", err.Error()))
+	}
+	expiry := info.MaxIdleExpirationTime
+	if expiry.IsZero() || (!info.MaxSessionExpirationTime.IsZero() && info.MaxSessionExpirationTime.Before(expiry)) {
+		expiry = info.MaxSessionExpirationTime
+	}
+	return expiry, nil
+}
+
+func (c *Client) refreshSession(ctx context.Context) error {
+	expiry, err := c.sessionExpiry(ctx, "refresh")
+	if err == nil && time.Until(expiry) > tokenRefreshMargin {
+		c.setSession(c.frt.tokenId, expiry)
+		return nil
+	}
+	// the session is gone or reached its maximum lifetime, only a new login helps
+	if c.frt.renewSession == nil {
+		return errors.New("ERROR: AM session is about to expire and can't be renewed\ncall UseCredentialRenewal before Authenticate to log in again when it does")
+	}
+	return c.frt.renewSession(ctx, c)
+}
+
+func (c *Client) refreshAccessToken(ctx context.Context) error {
+	req := c.request().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded")
+	form := map[string]string{
+		"grant_type":    "refresh_token",
+		"refresh_token": c.frt.refreshToken,
+	}
+	// authenticate as the client the token was issued to
+	if c.frt.clientId != "" {
+		req.SetBasicAuth(c.frt.clientId, c.frt.clientSecret)
+	} else if c.frt.deploymentType == "Cloud" {
+		req.SetBasicAuth(adminClientId, adminClientPassword)
+	} else {
+		form["client_id"] = adminClientId
+	}
+	resp1, err1 := req.
+		SetFormData(form).
+		Post(rootAccessTokenURL(c.frt.tenant))
+	if err1 != nil {
+		return errors.New(fmt.Sprintf("ERROR: refresh token call failed, %s", err1.Error()))
+	}
+	if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+		return newAPIError(resp1, fmt.Sprintf("ERROR: refresh token call returned %d", resp1.StatusCode()))
+	}
+	return c.setBearer(resp1.Body())
+}
+
+func (c *Client) refreshBearer(ctx context.Context) error {
+	if c.frt.refreshToken != "" && c.refreshAccessToken(ctx) == nil {
+		return nil
+	}
+	if c.frt.renewBearer == nil {
+		return errors.New("ERROR: access token is about to expire and can't be renewed")
+	}
+	return c.frt.renewBearer(ctx, c)
+}
+
+// EnsureFresh renews the AM session and the bearer token when they are about to expire,
+// every call does this on its own before it is sent. The renewed tokens are written to
+// the FRToken the Client was created with.
+func (c *Client) EnsureFresh(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	ctx = context.WithValue(ctx, skipRefreshKey{}, true)
+
+	if c.frt.tokenId != "" && !c.frt.sessionExpiry.IsZero() && time.Until(c.frt.sessionExpiry) < tokenRefreshMargin {
+		err := c.refreshSession(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if c.frt.bearerToken != "" && !c.frt.bearerExpiry.IsZero() && time.Until(c.frt.bearerExpiry) < tokenRefreshMargin {
+		err := c.refreshBearer(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshMiddleware renews expiring tokens before a request that uses them is sent and
+// puts the current tokens on it, they may have changed since the request was built. Other
+// requests, like those of a JourneyRunner, keep the headers and cookies they were given.
+func (c *Client) refreshMiddleware(rc *resty.Client, req *resty.Request) error {
+	if req.Context().Value(adminTokensKey{}) == nil {
+		return nil
+	}
+	bearer := strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ")
+	var session *http.Cookie
+	for _, cookie := range req.Cookies {
+		if cookie.Name == c.frt.cookieName && cookie.Value != "" {
+			session = cookie
+		}
+	}
+	if !bearer && session == nil {
+		return nil
+	}
+	if req.Context().Value(skipRefreshKey{}) == nil {
+		err := c.EnsureFresh(req.Context())
+		if err != nil {
+			return err
+		}
+	}
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	if bearer {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.frt.bearerToken))
+	}
+	if session != nil {
+		session.Value = c.frt.tokenId
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package frodolibs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer issues access tokens that are about to expire, and a refresh token, on
+// every grant except refresh_token, which gets a long lived "refreshed" token. It records
+// the path and grant of token calls and the Authorization header of all others.
+type tokenServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	grants []string
+	bearer []string
+}
+
+func newTokenServer() *tokenServer {
+	ts := &tokenServer{}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		if r.Method != http.MethodPost {
+			ts.bearer = append(ts.bearer, r.Header.Get("Authorization"))
+			w.Write([]byte(`{}`))
+			return
+		}
+		r.ParseForm()
+		grant := r.PostForm.Get("grant_type")
+		ts.grants = append(ts.grants, r.URL.Path+" "+grant)
+		if grant == "refresh_token" {
+			w.Write([]byte(`{"access_token": "refreshed", "expires_in": 3600}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "expiring", "refresh_token": "r1", "expires_in": 30}`))
+	}))
+	return ts
+}
+
+func TestRefreshAtIssuingEndpoint(t *testing.T) {
+	srv := newTokenServer()
+	defer srv.Close()
+	frt := NewFRToken(srv.URL, "/alpha")
+	frt.UseClientCredentials("client", "secret", "")
+	c, _ := NewClient(&frt)
+	err := c.GetAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetEmailTemplateData("welcome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/oauth2/access_token client_credentials", "/oauth2/access_token refresh_token"}
+	if len(srv.grants) != len(want) || srv.grants[0] != want[0] || srv.grants[1] != want[1] {
+		t.Errorf("token calls %q, want %q", srv.grants, want)
+	}
+	if len(srv.bearer) != 1 || srv.bearer[0] != "Bearer refreshed" {
+		t.Errorf("Authorization %q, want the refreshed token", srv.bearer)
+	}
+}
+
+func TestTokenRenewalPersistsInClient(t *testing.T) {
+	tests := []struct {
+		name string
+		// makes two calls with frt
+		calls      func(frt *FRToken) error
+		wantGrants int
+		wantBearer string
+	}{
+		{"package level functions renew a copy", func(frt *FRToken) error {
+			for i := 0; i < 2; i++ {
+				_, err := GetEmailTemplateData(*frt, "welcome")
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}, 3, "expiring"},
+		{"a Client renews its FRToken", func(frt *FRToken) error {
+			c, _ := NewClient(frt)
+			for i := 0; i < 2; i++ {
+				_, err := c.GetEmailTemplateData("welcome")
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}, 2, "refreshed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newTokenServer()
+			defer srv.Close()
+			frt := NewFRToken(srv.URL, "/")
+			frt.UseClientCredentials("client", "secret", "")
+			err := frt.GetAccessToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = test.calls(&frt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(srv.grants) != test.wantGrants {
+				t.Errorf("token calls %q, want %d", srv.grants, test.wantGrants)
+			}
+			if frt.GetBearerToken() != test.wantBearer {
+				t.Errorf("bearer token = %s, want %s", frt.GetBearerToken(), test.wantBearer)
+			}
+		})
+	}
+}
+
+// loginServer lets an admin log in and counts logins. Once *expiring is set, it keeps
+// sessions a few seconds from expiring, so refreshing them never helps.
+func loginServer() (*httptest.Server, *int32, *int32) {
+	var logins, expiring int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/serverinfo/*"):
+			w.Write([]byte(`{"cookieName": "iPlanetDirectoryPro"}`))
+		case strings.HasSuffix(r.URL.Path, "/serverinfo/version"):
+			w.Write([]byte(`{"version": "7.1.0"}`))
+		case strings.HasSuffix(r.URL.Path, "/authenticate"):
+			fmt.Fprintf(w, `{"tokenId": "t%d"}`, atomic.AddInt32(&logins, 1))
+		case strings.HasSuffix(r.URL.Path, "/sessions"):
+			lifetime := time.Hour
+			if atomic.LoadInt32(&expiring) == 1 {
+				lifetime = 5 * time.Second
+			}
+			fmt.Fprintf(w, `{"maxIdleExpirationTime": %q}`, time.Now().Add(lifetime).Format(time.RFC3339))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	return srv, &logins, &expiring
+}
+
+func TestCredentialRenewalIsOptIn(t *testing.T) {
+	tests := []struct {
+		name       string
+		renewal    bool
+		wantLogins int32
+		wantToken  string
+		wantErr    string
+	}{
+		{"credentials not kept", false, 1, "t1", "UseCredentialRenewal"},
+		{"credentials kept", true, 2, "t2", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, logins, expiring := loginServer()
+			defer srv.Close()
+			frt := NewFRToken(srv.URL, "/")
+			if test.renewal {
+				frt.UseCredentialRenewal()
+			}
+			c, _ := NewClient(&frt)
+			err := c.Authenticate("amadmin", "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			atomic.StoreInt32(expiring, 1)
+			c.setSession(frt.tokenId, time.Now().Add(5*time.Second))
+			err = c.EnsureFresh(context.Background())
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("error = %v, want it to contain %q", err, test.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if *logins != test.wantLogins {
+				t.Errorf("logins = %d, want %d", *logins, test.wantLogins)
+			}
+			if frt.GetTokenId() != test.wantToken {
+				t.Errorf("token id = %s, want %s", frt.GetTokenId(), test.wantToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package frodolibs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// formats RenderJourney can emit
+const (
+	RenderDOT     string = "dot"
+	RenderMermaid string = "mermaid"
+)
+
+// a node to draw, children is set for page nodes
+type renderNode struct {
+	id       string
+	label    string
+	children []renderNode
+}
+
+type renderEdge struct {
+	from    string
+	to      string
+	outcome string
+}
+
+// journeyGraph is the drawing of a journey independent of the output format, node ids are
+// short names that are valid in both formats, the static nodes are success and failure.
+type journeyGraph struct {
+	name       string
+	nodes      []renderNode
+	edges      []renderEdge
+	pages      map[string]renderNode
+	success    bool
+	failure    bool
+	renderedId map[string]string
+}
+
+// RenderJourney draws a GetJourneyData export as a Graphviz DOT or Mermaid flowchart,
+// format is RenderDOT or RenderMermaid. Nodes show their display name and type, edges
+// their outcome and page nodes are drawn around the nodes on the page.
+func RenderJourney(journeyMap map[string]interface{}, format string) (string, error) {
+	journey, err := JourneyFromMap(journeyMap)
+	if err != nil {
+		return "", err
+	}
+	return RenderJourneyTyped(journey, format)
+}
+
+func RenderJourneyTyped(journey Journey, format string) (string, error) {
+	graph := newJourneyGraph(journey)
+	switch strings.ToLower(format) {
+	case RenderDOT:
+		return graph.dot(), nil
+	case RenderMermaid:
+		return graph.mermaid(), nil
+	}
+	return "", errors.New(fmt.Sprintf("ERROR: unknown render format %s, expected %s or %s", format, RenderDOT, RenderMermaid))
+}
+
+func renderLabel(displayName string, nodeType string) string {
+	if displayName == "" || displayName == nodeType {
+		return nodeType
+	}
+	return displayName + "\n" + nodeType
+}
+
+func newJourneyGraph(journey Journey) *journeyGraph {
+	tree := journey.Tree
+	g := &journeyGraph{
+		name:       tree.Id,
+		pages:      make(map[string]renderNode),
+		renderedId: make(map[string]string),
+	}
+	nodeIds := make([]string, 0, len(tree.Nodes))
+	for nodeId := range tree.Nodes {
+		nodeIds = append(nodeIds, nodeId)
+	}
+	sort.Strings(nodeIds)
+	for i, nodeId := range nodeIds {
+		g.renderedId[nodeId] = fmt.Sprintf("n%d", i)
+	}
+
+	// the entry node first, so both formats lay the journey out from it
+	sort.SliceStable(nodeIds, func(i, j int) bool {
+		return nodeIds[i] == tree.EntryNodeId && nodeIds[j] != tree.EntryNodeId
+	})
+	for _, nodeId := range nodeIds {
+		nodeRef := tree.Nodes[nodeId]
+		node := renderNode{id: g.renderedId[nodeId], label: renderLabel(nodeRef.DisplayName, nodeRef.NodeType)}
+		if containerNodes[nodeRef.NodeType] {
+			if pageNode, err := journey.Nodes[nodeId].AsPageNode(); err == nil {
+				for i, child := range pageNode.Nodes {
+					node.children = append(node.children, renderNode{
+						id:    fmt.Sprintf("%s_%d", node.id, i),
+						label: renderLabel(child.DisplayName, child.NodeType),
+					})
+				}
+			}
+		}
+		if len(node.children) > 0 {
+			g.pages[node.id] = node
+		}
+		g.nodes = append(g.nodes, node)
+
+		outcomes := make([]string, 0, len(nodeRef.Connections))
+		for outcome := range nodeRef.Connections {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Strings(outcomes)
+		for _, outcome := range outcomes {
+			g.edges = append(g.edges, renderEdge{from: node.id, to: g.target(nodeRef.Connections[outcome]), outcome: outcome})
+		}
+	}
+	return g
+}
+
+// target returns the drawn id of the node an outcome connects to, a missing node is drawn
+// with its raw id so the broken connection shows.
+func (g *journeyGraph) target(nodeId string) string {
+	switch nodeId {
+	case SuccessNodeId:
+		g.success = true
+		return "success"
+	case FailureNodeId:
+		g.failure = true
+		return "failure"
+	}
+	if id, exists := g.renderedId[nodeId]; exists {
+		return id
+	}
+	id := fmt.Sprintf("missing%d", len(g.renderedId))
+	g.renderedId[nodeId] = id
+	g.nodes = append(g.nodes, renderNode{id: id, label: "missing\n" + nodeId})
+	return id
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+func (g *journeyGraph) dot() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(g.name))
+	// compound lets edges end at the border of a page
+	b.WriteString("  compound=true;\n  rankdir=LR;\n  node [shape=box, style=rounded];\n")
+	for _, node := range g.nodes {
+		if len(node.children) == 0 {
+			fmt.Fprintf(&b, "  %s [label=%s];\n", node.id, dotQuote(node.label))
+			continue
+		}
+		fmt.Fprintf(&b, "  subgraph cluster_%s {\n    label=%s;\n    style=dashed;\n", node.id, dotQuote(node.label))
+		for _, child := range node.children {
+			fmt.Fprintf(&b, "    %s [label=%s];\n", child.id, dotQuote(child.label))
+		}
+		b.WriteString("  }\n")
+	}
+	if g.success {
+		b.WriteString("  success [label=\"Success\", shape=doublecircle, style=filled, fillcolor=palegreen];\n")
+	}
+	if g.failure {
+		b.WriteString("  failure [label=\"Failure\", shape=doublecircle, style=filled, fillcolor=lightcoral];\n")
+	}
+	for _, edge := range g.edges {
+		from, to := edge.from, edge.to
+		var attrs []string
+		// edges of a page start at its last node and end at its first, clipped to the cluster
+		if page, exists := g.pages[from]; exists {
+			from = page.children[len(page.children)-1].id
+			attrs = append(attrs, "ltail=cluster_"+page.id)
+		}
+		if page, exists := g.pages[to]; exists {
+			to = page.children[0].id
+			attrs = append(attrs, "lhead=cluster_"+page.id)
+		}
+		attrs = append([]string{"label=" + dotQuote(edge.outcome)}, attrs...)
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", from, to, strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	return `"` + strings.ReplaceAll(s, "\n", "<br/>") + `"`
+}
+
+func (g *journeyGraph) mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, node := range g.nodes {
+		if len(node.children) == 0 {
+			fmt.Fprintf(&b, "  %s[%s]\n", node.id, mermaidQuote(node.label))
+			continue
+		}
+		fmt.Fprintf(&b, "  subgraph %s[%s]\n", node.id, mermaidQuote(node.label))
+		for _, child := range node.children {
+			fmt.Fprintf(&b, "    %s[%s]\n", child.id, mermaidQuote(child.label))
+		}
+		b.WriteString("  end\n")
+	}
+	if g.success {
+		b.WriteString("  success((Success))\n")
+	}
+	if g.failure {
+		b.WriteString("  failure((Failure))\n")
+	}
+	for _, edge := range g.edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", edge.from, mermaidQuote(edge.outcome), edge.to)
+	}
+	if g.success {
+		b.WriteString("  classDef successNode fill:#98fb98,stroke:#2e8b57\n  class success successNode\n")
+	}
+	if g.failure {
+		b.WriteString("  classDef failureNode fill:#f08080,stroke:#b22222\n  class failure failureNode\n")
+	}
+	return b.String()
+}
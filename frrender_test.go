@@ -0,0 +1,92 @@
+package frodolibs
+
+import (
+	"strings"
+	"testing"
+)
+
+// a page leading to a scripted node with a quote in its name, one outcome of which
+// connects to a node that isn't in the tree
+const renderFixture string = `{
+	"tree": {"_id": "Login", "entryNodeId": "page", "nodes": {
+		"page": {"nodeType": "PageNode", "displayName": "Credentials", "connections": {"outcome": "check"}},
+		"check": {"nodeType": "ScriptedDecisionNode", "displayName": "Say \"hi\"", "connections": {
+			"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0", "false": "e301438c-0bd0-429c-ab0c-66126501069a", "retry": "gone"}}}},
+	"nodes": {
+		"page": {"_id": "page", "_type": {"_id": "PageNode"}, "nodes": [
+			{"_id": "user", "nodeType": "UsernameCollectorNode", "displayName": "User Name"},
+			{"_id": "pass", "nodeType": "PasswordCollectorNode"}]},
+		"check": {"_id": "check", "_type": {"_id": "ScriptedDecisionNode"}, "script": "s1", "outcomes": ["true", "false", "retry"]}}
+}`
+
+func TestRenderJourney(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		want    string
+		wantErr string
+	}{
+		{"dot", RenderDOT, `digraph "Login" {
+  compound=true;
+  rankdir=LR;
+  node [shape=box, style=rounded];
+  subgraph cluster_n1 {
+    label="Credentials\nPageNode";
+    style=dashed;
+    n1_0 [label="User Name\nUsernameCollectorNode"];
+    n1_1 [label="PasswordCollectorNode"];
+  }
+  n0 [label="Say \"hi\"\nScriptedDecisionNode"];
+  missing2 [label="missing\ngone"];
+  success [label="Success", shape=doublecircle, style=filled, fillcolor=palegreen];
+  failure [label="Failure", shape=doublecircle, style=filled, fillcolor=lightcoral];
+  n1_1 -> n0 [label="outcome", ltail=cluster_n1];
+  n0 -> failure [label="false"];
+  n0 -> missing2 [label="retry"];
+  n0 -> success [label="true"];
+}
+`, ""},
+		{"mermaid", RenderMermaid, `flowchart LR
+  subgraph n1["Credentials<br/>PageNode"]
+    n1_0["User Name<br/>UsernameCollectorNode"]
+    n1_1["PasswordCollectorNode"]
+  end
+  n0["Say #quot;hi#quot;<br/>ScriptedDecisionNode"]
+  missing2["missing<br/>gone"]
+  success((Success))
+  failure((Failure))
+  n1 -->|"outcome"| n0
+  n0 -->|"false"| failure
+  n0 -->|"retry"| missing2
+  n0 -->|"true"| success
+  classDef successNode fill:#98fb98,stroke:#2e8b57
+  class success successNode
+  classDef failureNode fill:#f08080,stroke:#b22222
+  class failure failureNode
+`, ""},
+		{"format is case insensitive", "Mermaid", "flowchart LR\n", ""},
+		{"unknown format", "svg", "", "unknown render format svg"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rendered, err := RenderJourney(journeyFixture(t, renderFixture), test.format)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// a want of a single line only checks the start of the output
+			if strings.Count(test.want, "\n") == 1 {
+				if !strings.HasPrefix(rendered, test.want) {
+					t.Errorf("rendered = %q, want it to start with %q", rendered, test.want)
+				}
+			} else if rendered != test.want {
+				t.Errorf("rendered =\n%s\nwant\n%s", rendered, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package frodolibs
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RetryPolicy controls how a Client retries transient AM/IDM failures. Waits grow
+// exponentially from MinWait with jitter and are capped at MaxWait, a Retry-After
+// header on the response takes precedence when present. Only idempotent methods
+// are retried.
+type RetryPolicy struct {
+	MaxRetries  int
+	MinWait     time.Duration
+	MaxWait     time.Duration
+	StatusCodes []int
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:  3,
+		MinWait:     500 * time.Millisecond,
+		MaxWait:     10 * time.Second,
+		StatusCodes: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	}
+}
+
+// WithRetryPolicy replaces the default retry policy, a zero MaxRetries disables retries.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		applyRetryPolicy(c.httpClient, policy)
+		return nil
+	}
+}
+
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+func applyRetryPolicy(client *resty.Client, policy RetryPolicy) {
+	retryStatus := make(map[int]bool, len(policy.StatusCodes))
+	for _, code := range policy.StatusCodes {
+		retryStatus[code] = true
+	}
+	client.SetRetryCount(policy.MaxRetries).
+		SetRetryWaitTime(policy.MinWait).
+		SetRetryMaxWaitTime(policy.MaxWait).
+		SetRetryAfter(retryAfter)
+	client.RetryConditions = []resty.RetryConditionFunc{
+		func(resp *resty.Response, err error) bool {
+			if resp == nil || resp.Request == nil || !idempotentMethods[resp.Request.Method] {
+				return false
+			}
+			if err != nil {
+				// connection reset, timeout etc.
+				return true
+			}
+			return retryStatus[resp.StatusCode()]
+		},
+	}
+}
+
+// retryAfter reads the Retry-After header as seconds or an http date, returning 0 lets
+// resty fall back to jittered exponential backoff.
+func retryAfter(client *resty.Client, resp *resty.Response) (time.Duration, error) {
+	if resp == nil || resp.RawResponse == nil {
+		return 0, nil
+	}
+	value := resp.Header().Get("Retry-After")
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		if seconds <= 0 {
+			return 0, nil
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, nil
+	}
+	wait := time.Until(date)
+	if wait <= 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
@@ -0,0 +1,133 @@
+package frodolibs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func testRetryPolicy(maxRetries int) RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MaxRetries = maxRetries
+	policy.MinWait = time.Millisecond
+	policy.MaxWait = 100 * time.Millisecond
+	return policy
+}
+
+// failingServer answers the first failures calls with status and a Retry-After header
+// when retryAfter is set, then 200.
+func failingServer(failures int32, status int, retryAfter string) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	return srv, &calls
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		method     string
+		failures   int32
+		status     int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{"listed status retried then ok", testRetryPolicy(3), resty.MethodGet, 2, http.StatusServiceUnavailable, http.StatusOK, 3},
+		{"gives up after MaxRetries", testRetryPolicy(2), resty.MethodGet, 5, http.StatusBadGateway, http.StatusBadGateway, 3},
+		{"put is retried", testRetryPolicy(3), resty.MethodPut, 1, http.StatusTooManyRequests, http.StatusOK, 2},
+		{"post not retried", testRetryPolicy(3), resty.MethodPost, 1, http.StatusServiceUnavailable, http.StatusServiceUnavailable, 1},
+		{"unlisted status not retried", testRetryPolicy(3), resty.MethodGet, 1, http.StatusInternalServerError, http.StatusInternalServerError, 1},
+		{"zero MaxRetries disables retries", testRetryPolicy(0), resty.MethodGet, 1, http.StatusServiceUnavailable, http.StatusServiceUnavailable, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, calls := failingServer(test.failures, test.status, "")
+			defer srv.Close()
+			c, err := NewClient(&FRToken{tenant: srv.URL}, WithRetryPolicy(test.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.request().Execute(test.method, srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode() != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode(), test.wantStatus)
+			}
+			if *calls != test.wantCalls {
+				t.Errorf("calls = %d, want %d", *calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryAfterIsCappedAtMaxWait(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+	}{
+		{"seconds", "5"},
+		{"http date", time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, calls := failingServer(1, http.StatusTooManyRequests, test.retryAfter)
+			defer srv.Close()
+			policy := testRetryPolicy(1)
+			c, _ := NewClient(&FRToken{tenant: srv.URL}, WithRetryPolicy(policy))
+			start := time.Now()
+			resp, err := c.request().Get(srv.URL)
+			elapsed := time.Since(start)
+			if err != nil || resp.StatusCode() != http.StatusOK || *calls != 2 {
+				t.Fatalf("got %v, %v after %d calls", resp, err, *calls)
+			}
+			// backoff alone would wait about MinWait, the header asks for 5s
+			if elapsed < policy.MaxWait*9/10 || elapsed > 2*time.Second {
+				t.Errorf("waited %s, want about %s", elapsed, policy.MaxWait)
+			}
+		})
+	}
+}
+
+func TestRetryAfterParsing(t *testing.T) {
+	date := time.Now().Add(30 * time.Second).UTC()
+	tests := []struct {
+		value   string
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"0", 0, 0},
+		{"-2", 0, 0},
+		{date.Format(http.TimeFormat), 28 * time.Second, 30 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+		{"soon", 0, 0},
+	}
+	for _, test := range tests {
+		raw := &http.Response{Header: http.Header{}}
+		if test.value != "" {
+			raw.Header.Set("Retry-After", test.value)
+		}
+		got, err := retryAfter(nil, &resty.Response{RawResponse: raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got < test.wantMin || got > test.wantMax {
+			t.Errorf("retryAfter(%q) = %s, want between %s and %s", test.value, got, test.wantMin, test.wantMax)
+		}
+	}
+}
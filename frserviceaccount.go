@@ -0,0 +1,169 @@
+package frodolibs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const serviceAccountClientId string = "service-account"
+const serviceAccountScope string = "fr:am:* fr:idm:* fr:idc:esv:*"
+const jwtBearerGrantType string = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// the assertion is only used once, right away
+const serviceAccountJWTLifetime time.Duration = 3 * time.Minute
+
+type rsaJWK struct {
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	D   string `json:"d"`
+	P   string `json:"p"`
+	Q   string `json:"q"`
+}
+
+func decodeJWKInt(name string, value string) (*big.Int, error) {
+	if value == "" {
+		return nil, errors.New(fmt.Sprintf("ERROR: jwk has no %s", name))
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ERROR: fail to decode jwk %s, %s", name, err.Error()))
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// ParseRSAJWK reads an RSA private key in JWK form, as downloaded when creating an
+// Identity Cloud service account.
+func ParseRSAJWK(jwk []byte) (*rsa.PrivateKey, error) {
+	var key rsaJWK
+	err := json.Unmarshal(jwk, &key)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ERROR: fail to unmarshal jwk json, %s", err.Error()))
+	}
+	if key.Kty != "RSA" {
+		return nil, errors.New(fmt.Sprintf("ERROR: jwk key type %s is not supported, expected RSA", key.Kty))
+	}
+	ints := make(map[string]*big.Int)
+	for _, field := range []struct{ name, value string }{{"n", key.N}, {"e", key.E}, {"d", key.D}, {"p", key.P}, {"q", key.Q}} {
+		ints[field.name], err = decodeJWKInt(field.name, field.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	privateKey := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: ints["n"], E: int(ints["e"].Int64())},
+		D:         ints["d"],
+		Primes:    []*big.Int{ints["p"], ints["q"]},
+	}
+	err = privateKey.Validate()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ERROR: invalid jwk private key, %s", err.Error()))
+	}
+	privateKey.Precompute()
+	return privateKey, nil
+}
+
+// SignServiceAccountJWT builds the RS256 assertion a service account exchanges for an
+// access token at audience.
+func SignServiceAccountJWT(serviceAccountId string, audience string, key *rsa.PrivateKey) (string, error) {
+	jti, err := GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": serviceAccountId,
+		"sub": serviceAccountId,
+		"aud": audience,
+		"exp": time.Now().Add(serviceAccountJWTLifetime).Unix(),
+		"jti": jti,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("ERROR: fail to sign jwt, %s", err.Error()))
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// AuthenticateServiceAccount gets a bearer token for an Identity Cloud service account
+// with the jwt-bearer grant, instead of logging in as an admin user. The token is used
+// for both AM and IDM calls.
+func (c *Client) AuthenticateServiceAccount(serviceAccountId string, jwk []byte) error {
+	return c.AuthenticateServiceAccountContext(context.Background(), serviceAccountId, jwk)
+}
+
+func (c *Client) AuthenticateServiceAccountContext(ctx context.Context, serviceAccountId string, jwk []byte) error {
+	key, err := ParseRSAJWK(jwk)
+	if err != nil {
+		return err
+	}
+	err = c.serviceAccountToken(ctx, serviceAccountId, key)
+	if err != nil {
+		return err
+	}
+	c.setSession("", time.Time{})
+	// a new assertion is signed for every token, there is no refresh token
+	c.frt.renewBearer = func(ctx context.Context, c *Client) error {
+		return c.serviceAccountToken(ctx, serviceAccountId, key)
+	}
+	// service accounts only exist in Identity Cloud
+	c.frt.deploymentType = "Cloud"
+	c.GetVersionInfoContext(ctx)
+	return nil
+}
+
+func (c *Client) serviceAccountToken(ctx context.Context, serviceAccountId string, key *rsa.PrivateKey) error {
+	// the access_token endpoint of the root realm is also the audience of the assertion
+	accessTokenURL := rootAccessTokenURL(c.frt.tenant)
+	assertion, err := SignServiceAccountJWT(serviceAccountId, accessTokenURL, key)
+	if err != nil {
+		return err
+	}
+
+	resp1, err1 := c.request().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetFormData(map[string]string{
+			"client_id":  serviceAccountClientId,
+			"grant_type": jwtBearerGrantType,
+			"assertion":  assertion,
+			"scope":      serviceAccountScope,
+		}).
+		Post(accessTokenURL)
+	if err1 != nil {
+		return errors.New(fmt.Sprintf("ERROR: service account access token call failed, %s\n", err1.Error()))
+	}
+	if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+		return newAPIError(resp1, fmt.Sprintf("ERROR: service account access token call returned %d\nlikely cause: unknown service account or wrong key", resp1.StatusCode()))
+	}
+	err2 := c.setBearer(resp1.Body())
+	if err2 != nil {
+		return errors.New(fmt.Sprintf("ERROR: can not extract access token from response, %s\n", err2.Error()))
+	}
+	return nil
+}
+
+func (frt *FRToken) AuthenticateServiceAccount(serviceAccountId string, jwk []byte) error {
+	return defaultClient(frt).AuthenticateServiceAccount(serviceAccountId, jwk)
+}
+
+func (frt *FRToken) AuthenticateServiceAccountContext(ctx context.Context, serviceAccountId string, jwk []byte) error {
+	return defaultClient(frt).AuthenticateServiceAccountContext(ctx, serviceAccountId, jwk)
+}
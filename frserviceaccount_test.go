@@ -0,0 +1,162 @@
+package frodolibs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testJWK(t *testing.T, key *rsa.PrivateKey) []byte {
+	t.Helper()
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	jwk, err := json.Marshal(map[string]string{
+		"kty": "RSA",
+		"n":   encode(key.N),
+		"e":   encode(big.NewInt(int64(key.E))),
+		"d":   encode(key.D),
+		"p":   encode(key.Primes[0]),
+		"q":   encode(key.Primes[1]),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwk
+}
+
+// verifyJWT checks the RS256 signature of jwt with key and returns its claims.
+func verifyJWT(t *testing.T, jwt string, key *rsa.PublicKey) map[string]interface{} {
+	t.Helper()
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("assertion has %d parts, want 3", len(parts))
+	}
+	var header map[string]string
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	json.Unmarshal(headerJSON, &header)
+	if header["alg"] != "RS256" {
+		t.Errorf("alg = %s, want RS256", header["alg"])
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature)
+	if err != nil {
+		t.Fatalf("assertion signature doesn't verify, %v", err)
+	}
+	var claims map[string]interface{}
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	err = json.Unmarshal(claimsJSON, &claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestParseRSAJWK(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseRSAJWK(testJWK(t, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.N.Cmp(key.N) != 0 || parsed.D.Cmp(key.D) != 0 || parsed.E != key.E {
+		t.Error("parsed key differs from the generated one")
+	}
+
+	tests := []struct {
+		name string
+		jwk  string
+	}{
+		{"not json", `{`},
+		{"ec key", `{"kty":"EC"}`},
+		{"missing d", `{"kty":"RSA","n":"AQAB","e":"AQAB"}`},
+	}
+	for _, test := range tests {
+		_, err := ParseRSAJWK([]byte(test.jwk))
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestAuthenticateServiceAccount(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const serviceAccountId = "0b1d5c2e-sa"
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/oauth2/access_token":
+			r.ParseForm()
+			for field, want := range map[string]string{
+				"grant_type": "urn:ietf:params:oauth:grant-type:jwt-bearer",
+				"client_id":  "service-account",
+				"scope":      "fr:am:* fr:idm:* fr:idc:esv:*",
+			} {
+				if got := r.PostForm.Get(field); got != want {
+					t.Errorf("form %s = %q, want %q", field, got, want)
+				}
+			}
+			claims := verifyJWT(t, r.PostForm.Get("assertion"), &key.PublicKey)
+			if claims["aud"] != srv.URL+"/oauth2/access_token" {
+				t.Errorf("aud = %v", claims["aud"])
+			}
+			if claims["iss"] != serviceAccountId || claims["sub"] != serviceAccountId {
+				t.Errorf("iss = %v, sub = %v, want %s", claims["iss"], claims["sub"], serviceAccountId)
+			}
+			exp, _ := claims["exp"].(float64)
+			if lifetime := time.Until(time.Unix(int64(exp), 0)); lifetime <= 0 || lifetime > 5*time.Minute {
+				t.Errorf("exp is %s from now", lifetime)
+			}
+			w.Write([]byte(`{"access_token":"sa-token","expires_in":899}`))
+		default:
+			// AM calls take the access token instead of a session
+			if got := r.Header.Get("Authorization"); got != "Bearer sa-token" {
+				t.Errorf("%s Authorization = %q, want the bearer token", r.URL.Path, got)
+			}
+			if len(r.Cookies()) > 0 {
+				t.Errorf("%s sent cookies %v", r.URL.Path, r.Cookies())
+			}
+			if strings.HasSuffix(r.URL.Path, "/serverinfo/version") {
+				w.Write([]byte(`{"version":"7.3.0","fullVersion":"ForgeRock Access Management 7.3.0"}`))
+				return
+			}
+			w.Write([]byte(`{"_id":"Login","entryNodeId":"a","nodes":{}}`))
+		}
+	}))
+	defer srv.Close()
+
+	frt := NewFRToken(srv.URL, "alpha")
+	c, _ := NewClient(&frt)
+	err = c.AuthenticateServiceAccount(serviceAccountId, testJWK(t, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frt.GetBearerToken() != "sa-token" || frt.GetTokenId() != "" || frt.GetDeploymentType() != "Cloud" {
+		t.Errorf("got bearer %q, session %q, deployment %q", frt.GetBearerToken(), frt.GetTokenId(), frt.GetDeploymentType())
+	}
+	if lifetime, ok := frt.BearerTokenLifetime(); !ok || lifetime < 14*time.Minute {
+		t.Errorf("bearer lifetime = %s, %v", lifetime, ok)
+	}
+	_, err = c.GetTreeData("Login")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
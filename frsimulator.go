@@ -0,0 +1,183 @@
+package frodolibs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// steps a simulation may take before it is considered stuck in a loop
+const defaultMaxSimulationSteps int = 100
+
+// JourneySimulator walks the graph of a journey export without a tenant, taking the
+// outcome the caller decided for each node. For a node the outcome is looked up in
+// NodeOutcomes, then ScriptOutcomes for scripted nodes, then Outcomes. A node with a
+// single outcome, like a page node, takes it when none is given. An inner tree evaluator
+// without an outcome runs the inner tree if the export has it under "innerTrees".
+type JourneySimulator struct {
+	// outcome per node type, e.g. {"DataStoreDecisionNode": "true"}
+	Outcomes map[string]string
+	// outcomes per node id or display name, the first visit takes the first outcome and so
+	// on, the last one is repeated
+	NodeOutcomes map[string][]string
+	// outcomes per script name or id for ScriptedDecisionNodes, taken like NodeOutcomes
+	ScriptOutcomes map[string][]string
+	// defaults to 100
+	MaxSteps int
+}
+
+type SimulationStep struct {
+	Tree        string
+	NodeId      string
+	NodeType    string
+	DisplayName string
+	Outcome     string
+}
+
+// SimulationResult is the path a simulation took. EndNodeId is SuccessNodeId or
+// FailureNodeId once the journey ended.
+type SimulationResult struct {
+	Path      []SimulationStep
+	EndNodeId string
+}
+
+func (r *SimulationResult) Succeeded() bool {
+	return r.EndNodeId == SuccessNodeId
+}
+
+func (r *SimulationResult) Failed() bool {
+	return r.EndNodeId == FailureNodeId
+}
+
+// SimulateJourney walks a GetJourneyData export with sim.
+func SimulateJourney(journeyMap map[string]interface{}, sim JourneySimulator) (*SimulationResult, error) {
+	journey, err := JourneyFromMap(journeyMap)
+	if err != nil {
+		return &SimulationResult{}, err
+	}
+	return sim.Simulate(journey)
+}
+
+// Simulate returns the path taken so far along with the error when a node has no outcome
+// or the outcome isn't connected.
+func (s JourneySimulator) Simulate(journey Journey) (*SimulationResult, error) {
+	result := &SimulationResult{}
+	sim := &simulation{result: result, visits: make(map[string]int), innerTrees: make(map[string]Journey)}
+	collectInnerTrees(journey, sim.innerTrees)
+	endNodeId, err := s.simulate(journey, sim)
+	result.EndNodeId = endNodeId
+	return result, err
+}
+
+type simulation struct {
+	result     *SimulationResult
+	visits     map[string]int
+	innerTrees map[string]Journey
+}
+
+// collectInnerTrees indexes the inner trees of a deep export by name, each tree is only
+// exported once, under the first tree that calls it.
+func collectInnerTrees(journey Journey, innerTrees map[string]Journey) {
+	for name, innerJourney := range journey.InnerTrees {
+		innerTrees[name] = innerJourney
+		collectInnerTrees(innerJourney, innerTrees)
+	}
+}
+
+func (s JourneySimulator) simulate(journey Journey, sim *simulation) (string, error) {
+	result := sim.result
+	maxSteps := s.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = defaultMaxSimulationSteps
+	}
+	tree := journey.Tree
+	nodeId := tree.EntryNodeId
+	for {
+		if nodeId == SuccessNodeId || nodeId == FailureNodeId {
+			return nodeId, nil
+		}
+		if len(result.Path) >= maxSteps {
+			return "", errors.New(fmt.Sprintf("ERROR: journey %s didn't end after %d nodes\nlikely cause: a loop the outcomes never leave", tree.Id, maxSteps))
+		}
+		nodeRef, exists := tree.Nodes[nodeId]
+		if !exists {
+			return "", errors.New(fmt.Sprintf("ERROR: journey %s has no node %s", tree.Id, nodeId))
+		}
+		outcome, err := s.outcome(journey, nodeId, nodeRef, sim)
+		if err != nil {
+			return "", err
+		}
+		result.Path = append(result.Path, SimulationStep{
+			Tree:        tree.Id,
+			NodeId:      nodeId,
+			NodeType:    nodeRef.NodeType,
+			DisplayName: nodeRef.DisplayName,
+			Outcome:     outcome,
+		})
+		next, exists := nodeRef.Connections[outcome]
+		if !exists {
+			return "", errors.New(fmt.Sprintf("ERROR: outcome %s of node %s (%s) in journey %s is not connected", outcome, nodeRef.DisplayName, nodeId, tree.Id))
+		}
+		nodeId = next
+	}
+}
+
+func (s JourneySimulator) outcome(journey Journey, nodeId string, nodeRef NodeRef, sim *simulation) (string, error) {
+	node := journey.Nodes[nodeId]
+	visitKey := journey.Tree.Id + "/" + nodeId
+	visit := sim.visits[visitKey]
+	sim.visits[visitKey]++
+
+	if outcome, exists := scriptedOutcome(s.NodeOutcomes, visit, nodeId, nodeRef.DisplayName); exists {
+		return outcome, nil
+	}
+	if scriptedNodes[nodeRef.NodeType] {
+		if scriptId, exists := node.StringProperty("script"); exists {
+			if outcome, exists := scriptedOutcome(s.ScriptOutcomes, visit, journey.Scripts[scriptId].Name, scriptId); exists {
+				return outcome, nil
+			}
+		}
+	}
+	if outcome, exists := s.Outcomes[nodeRef.NodeType]; exists {
+		return outcome, nil
+	}
+	if innerTree, exists := node.StringProperty("tree"); exists && nodeRef.NodeType == "InnerTreeEvaluatorNode" {
+		if innerJourney, exists := sim.innerTrees[innerTree]; exists {
+			endNodeId, err := s.simulate(innerJourney, sim)
+			if err != nil {
+				return "", err
+			}
+			if endNodeId == SuccessNodeId {
+				return "true", nil
+			}
+			return "false", nil
+		}
+	}
+	if len(nodeRef.Connections) == 1 {
+		for outcome := range nodeRef.Connections {
+			return outcome, nil
+		}
+	}
+	outcomes := make([]string, 0, len(nodeRef.Connections))
+	for outcome := range nodeRef.Connections {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Strings(outcomes)
+	return "", errors.New(fmt.Sprintf("ERROR: no outcome given for node %s (%s, %s) in journey %s, connected outcomes are %v", nodeRef.DisplayName, nodeRef.NodeType, nodeId, journey.Tree.Id, outcomes))
+}
+
+// scriptedOutcome returns the outcome for the visit-th visit from the first key found in
+// outcomes.
+func scriptedOutcome(outcomes map[string][]string, visit int, keys ...string) (string, bool) {
+	for _, key := range keys {
+		list := outcomes[key]
+		if key == "" || len(list) == 0 {
+			continue
+		}
+		if visit >= len(list) {
+			visit = len(list) - 1
+		}
+		return list[visit], true
+	}
+	return "", false
+}
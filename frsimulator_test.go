@@ -0,0 +1,82 @@
+package frodolibs
+
+import (
+	"strings"
+	"testing"
+)
+
+const simulatorFixture string = `{
+	"tree": {"_id": "Login", "entryNodeId": "page", "nodes": {
+		"page": {"nodeType": "PageNode", "displayName": "Credentials", "connections": {"outcome": "check"}},
+		"check": {"nodeType": "ScriptedDecisionNode", "displayName": "Check", "connections": {"retry": "page", "ok": "mfa"}},
+		"mfa": {"nodeType": "InnerTreeEvaluatorNode", "displayName": "MFA", "connections": {
+			"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0", "false": "e301438c-0bd0-429c-ab0c-66126501069a"}}}},
+	"nodes": {
+		"page": {"_id": "page", "_type": {"_id": "PageNode"}, "nodes": []},
+		"check": {"_id": "check", "_type": {"_id": "ScriptedDecisionNode"}, "script": "s1", "outcomes": ["retry", "ok"]},
+		"mfa": {"_id": "mfa", "_type": {"_id": "InnerTreeEvaluatorNode"}, "tree": "MFA"}},
+	"scripts": {"s1": {"_id": "s1", "name": "checker", "script": ""}},
+	"innerTrees": {"MFA": {
+		"tree": {"_id": "MFA", "entryNodeId": "otp", "nodes": {
+			"otp": {"nodeType": "OneTimePasswordCollectorDecisionNode", "connections": {
+				"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0", "false": "e301438c-0bd0-429c-ab0c-66126501069a"}}}},
+		"nodes": {"otp": {"_id": "otp", "_type": {"_id": "OneTimePasswordCollectorDecisionNode"}}}}}
+}`
+
+// pathString lists the steps as tree/node=outcome
+func pathString(path []SimulationStep) string {
+	steps := make([]string, 0, len(path))
+	for _, step := range path {
+		steps = append(steps, step.Tree+"/"+step.NodeId+"="+step.Outcome)
+	}
+	return strings.Join(steps, " ")
+}
+
+func TestSimulateJourney(t *testing.T) {
+	tests := []struct {
+		name    string
+		sim     JourneySimulator
+		wantEnd string
+		want    string
+		wantErr string
+	}{
+		{"inner tree succeeds", JourneySimulator{
+			ScriptOutcomes: map[string][]string{"checker": {"ok"}},
+			Outcomes:       map[string]string{"OneTimePasswordCollectorDecisionNode": "true"},
+		}, SuccessNodeId, "Login/page=outcome Login/check=ok MFA/otp=true Login/mfa=true", ""},
+		{"scripted outcomes per visit", JourneySimulator{
+			ScriptOutcomes: map[string][]string{"s1": {"retry", "ok"}},
+			Outcomes:       map[string]string{"OneTimePasswordCollectorDecisionNode": "false"},
+		}, FailureNodeId, "Login/page=outcome Login/check=retry Login/page=outcome Login/check=ok MFA/otp=false Login/mfa=false", ""},
+		{"node outcome by display name wins", JourneySimulator{
+			NodeOutcomes:   map[string][]string{"Check": {"ok"}, "MFA": {"true"}},
+			ScriptOutcomes: map[string][]string{"checker": {"retry"}},
+		}, SuccessNodeId, "Login/page=outcome Login/check=ok Login/mfa=true", ""},
+		{"missing outcome", JourneySimulator{}, "", "Login/page=outcome", "no outcome given for node Check"},
+		{"unconnected outcome", JourneySimulator{
+			NodeOutcomes: map[string][]string{"check": {"maybe"}},
+		}, "", "Login/page=outcome Login/check=maybe", "outcome maybe of node Check (check) in journey Login is not connected"},
+		{"loop", JourneySimulator{
+			ScriptOutcomes: map[string][]string{"checker": {"retry"}},
+			MaxSteps:       10,
+		}, "", "", "didn't end after 10 nodes"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := SimulateJourney(journeyFixture(t, simulatorFixture), test.sim)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, test.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if result.EndNodeId != test.wantEnd {
+				t.Errorf("EndNodeId = %s, want %s", result.EndNodeId, test.wantEnd)
+			}
+			if test.want != "" && pathString(result.Path) != test.want {
+				t.Errorf("path = %s, want %s", pathString(result.Path), test.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package frodolibs
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	cv "github.com/jimlambrt/go-oauth-pkce-code-verifier"
@@ -37,6 +39,24 @@ type FRToken struct {
 	bearerToken    string
 	deploymentType string
 	version        string
+	// set by UseClientCredentials
+	clientId     string
+	clientSecret string
+	scope        string
+	// set by UseCredentialRenewal
+	credentialRenewal bool
+	// expiry of the tokens and how to get new ones, zero times mean unknown
+	sessionExpiry time.Time
+	bearerExpiry  time.Time
+	refreshToken  string
+	renewSession  func(ctx context.Context, c *Client) error
+	renewBearer   func(ctx context.Context, c *Client) error
+}
+
+// rootAccessTokenURL is the access_token endpoint of the root realm, which issues all
+// tokens used for admin calls and refreshes them
+func rootAccessTokenURL(tenant string) string {
+	return fmt.Sprintf(accessTokenURLTemplate, tenant, "")
 }
 
 func NewFRToken(tenant string, realm string) FRToken {
@@ -62,35 +82,34 @@ func (frt *FRToken) GetVersion() string {
 	return frt.version
 }
 
-func (frt *FRToken) DetermineDeployment() error {
-	// cookieName, _ := GetCookieName(frt.tenant)
+func (c *Client) DetermineDeployment() error {
+	return c.DetermineDeploymentContext(context.Background())
+}
+
+func (c *Client) DetermineDeploymentContext(ctx context.Context) error {
+	// cookieName, _ := GetCookieName(c.frt.tenant)
 	fidcClientId := "idmAdminClient"
 	forgeopsClientId := "idm-admin-ui"
 
 	// try to get fidcClientId first
-	client := resty.New()
-	resp1, err1 := client.R().
-		SetHeader("Accept-API-Version", amApiVersion).
-		SetCookie(&http.Cookie{Name: frt.cookieName, Value: frt.tokenId}).
-		Get(fmt.Sprintf(oauthClientURLTemplate, frt.tenant, "/alpha", fidcClientId))
+	resp1, err1 := c.amRequest(ctx).
+		Get(fmt.Sprintf(oauthClientURLTemplate, c.frt.tenant, "/alpha", fidcClientId))
 	if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
 		if resp1.StatusCode() == 404 {
 			// not found - try for forgeopsClientId
-			resp2, err2 := client.R().
-				SetHeader("Accept-API-Version", amApiVersion).
-				SetCookie(&http.Cookie{Name: frt.cookieName, Value: frt.tokenId}).
-				Get(fmt.Sprintf(oauthClientURLTemplate, frt.tenant, "", forgeopsClientId))
+			resp2, err2 := c.amRequest(ctx).
+				Get(fmt.Sprintf(oauthClientURLTemplate, c.frt.tenant, "", forgeopsClientId))
 			if resp2.StatusCode() < 200 || resp2.StatusCode() > 399 {
 				if resp2.StatusCode() == 404 {
 					// not found, its on-prem
 					fmt.Printf("No known OAuth clients found, likely classic deployment\n")
-					frt.deploymentType = "Classic"
+					c.frt.deploymentType = "Classic"
 				}
 			} else {
 				if err2 == nil {
 					fmt.Printf("%s found, likely ForgeOps deployment\n", forgeopsClientId)
 					adminClientId = forgeopsClientId
-					frt.deploymentType = "ForgeOps"
+					c.frt.deploymentType = "ForgeOps"
 				} else {
 					// log.Printf("Error %s\n", err1.Error())
 					return errors.New(fmt.Sprintf("ERROR: error determining deployment: %s\n", err2.Error()))
@@ -98,12 +117,12 @@ func (frt *FRToken) DetermineDeployment() error {
 			}
 		} else {
 			// log.Printf("Error %d\n", resp1.StatusCode())
-			return errors.New(fmt.Sprintf("ERROR: determine deployment call returned %d", resp1.StatusCode()))
+			return newAPIError(resp1, fmt.Sprintf("ERROR: determine deployment call returned %d", resp1.StatusCode()))
 		}
 	} else {
 		if err1 == nil {
 			fmt.Printf("%s found, likely ForgeRock ID Cloud\n", fidcClientId)
-			frt.deploymentType = "Cloud"
+			c.frt.deploymentType = "Cloud"
 		} else {
 			// log.Printf("Error %s\n", err1.Error())
 			return errors.New(fmt.Sprintf("ERROR: error determining deployment: %s\n", err1.Error()))
@@ -112,18 +131,27 @@ func (frt *FRToken) DetermineDeployment() error {
 	return nil
 }
 
-func (frt *FRToken) GetVersionInfo() error {
+func (frt *FRToken) DetermineDeployment() error {
+	return defaultClient(frt).DetermineDeployment()
+}
+
+func (frt *FRToken) DetermineDeploymentContext(ctx context.Context) error {
+	return defaultClient(frt).DetermineDeploymentContext(ctx)
+}
+
+func (c *Client) GetVersionInfo() error {
+	return c.GetVersionInfoContext(context.Background())
+}
+
+func (c *Client) GetVersionInfoContext(ctx context.Context) error {
 	// cookieName, _ := GetCookieName(tenant)
 
-	client := resty.New()
-	resp1, err1 := client.R().
-		SetHeader("Accept-API-Version", amApiVersion).
-		SetCookie(&http.Cookie{Name: frt.cookieName, Value: frt.tokenId}).
-		Get(fmt.Sprintf(serverInfoURLTemplate, frt.tenant, "version"))
+	resp1, err1 := c.amRequest(ctx).
+		Get(fmt.Sprintf(serverInfoURLTemplate, c.frt.tenant, "version"))
 
 	if err1 == nil {
 		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
-			return errors.New(fmt.Sprintf("ERROR: get version call returned %d", resp1.StatusCode()))
+			return newAPIError(resp1, fmt.Sprintf("ERROR: get version call returned %d", resp1.StatusCode()))
 		}
 		jsonMap := make(map[string](interface{}))
 		responseBody := resp1.Body()
@@ -138,7 +166,7 @@ func (frt *FRToken) GetVersionInfo() error {
 
 		versionString = fmt.Sprintf("%s", re.Find([]byte(version)))
 		log.Printf("version: %s\n", versionString)
-		frt.version = versionString
+		c.frt.version = versionString
 		fullVersion := jsonMap["fullVersion"]
 		fmt.Printf("Connected to %s\n", fullVersion)
 		return nil
@@ -148,13 +176,24 @@ func (frt *FRToken) GetVersionInfo() error {
 	}
 }
 
-func (frt *FRToken) GetCookieName() error {
-	client := resty.New()
-	resp1, err1 := client.R().Get(fmt.Sprintf(serverInfoURLTemplate, frt.tenant, "*"))
+func (frt *FRToken) GetVersionInfo() error {
+	return defaultClient(frt).GetVersionInfo()
+}
+
+func (frt *FRToken) GetVersionInfoContext(ctx context.Context) error {
+	return defaultClient(frt).GetVersionInfoContext(ctx)
+}
+
+func (c *Client) GetCookieName() error {
+	return c.GetCookieNameContext(context.Background())
+}
+
+func (c *Client) GetCookieNameContext(ctx context.Context) error {
+	resp1, err1 := c.request().SetContext(ctx).Get(fmt.Sprintf(serverInfoURLTemplate, c.frt.tenant, "*"))
 
 	if err1 == nil {
 		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
-			return errors.New(fmt.Sprintf("ERROR: get cookie call returned %d", resp1.StatusCode()))
+			return newAPIError(resp1, fmt.Sprintf("ERROR: get cookie call returned %d", resp1.StatusCode()))
 		}
 		jsonMap := make(map[string](interface{}))
 		err2 := json.Unmarshal([]byte(resp1.Body()), &jsonMap)
@@ -162,13 +201,21 @@ func (frt *FRToken) GetCookieName() error {
 			return errors.New(fmt.Sprintf("ERROR: fail to unmarshal json, %s", err2.Error()))
 		}
 		cookieName := jsonMap["cookieName"].(interface{})
-		frt.cookieName = cookieName.(string)
+		c.frt.cookieName = cookieName.(string)
 		return nil
 	} else {
 		return errors.New(fmt.Sprintf("ERROR: error getting cookie name, %s\n", err1.Error()))
 	}
 }
 
+func (frt *FRToken) GetCookieName() error {
+	return defaultClient(frt).GetCookieName()
+}
+
+func (frt *FRToken) GetCookieNameContext(ctx context.Context) error {
+	return defaultClient(frt).GetCookieNameContext(ctx)
+}
+
 func AuthCodeExtractRedirectPolicy() resty.RedirectPolicy {
 	fn := resty.RedirectPolicyFunc(func(req *http.Request, via []*http.Request) error {
 		queryString, _ := url.ParseQuery(req.URL.RawQuery)
@@ -181,7 +228,33 @@ func AuthCodeExtractRedirectPolicy() resty.RedirectPolicy {
 	return fn
 }
 
-func (frt *FRToken) GetAccessToken() error {
+// UseClientCredentials makes GetAccessToken use the client_credentials grant with a
+// confidential OAuth2 client instead of the authorization code flow, so no AM session is
+// needed. An empty scope asks for the IDM admin scope.
+func (frt *FRToken) UseClientCredentials(clientId string, clientSecret string, scope string) {
+	if scope == "" {
+		scope = idmAdminScope
+	}
+	frt.clientId = clientId
+	frt.clientSecret = clientSecret
+	frt.scope = scope
+}
+
+// UseCredentialRenewal makes Authenticate keep the admin username and password in memory
+// for the lifetime of the token, to log in again when the session reaches its maximum
+// lifetime during a long run. Without it such a session can't be renewed.
+func (frt *FRToken) UseCredentialRenewal() {
+	frt.credentialRenewal = true
+}
+
+func (c *Client) GetAccessToken() error {
+	return c.GetAccessTokenContext(context.Background())
+}
+
+func (c *Client) GetAccessTokenContext(ctx context.Context) error {
+	if c.frt.clientId != "" {
+		return c.getClientCredentialsToken(ctx)
+	}
 
 	v, _ := cv.CreateCodeVerifier()
 	codeVerifier := v.String()
@@ -190,24 +263,22 @@ func (frt *FRToken) GetAccessToken() error {
 	codeChallengeMethod := "S256"
 
 	// the authorize and access_token urls always are root realms when admin tokens are needed
-	authorizeURL := fmt.Sprintf(authorizeURLTemplate, frt.tenant, "/")
-	accessTokenURL := fmt.Sprintf(accessTokenURLTemplate, frt.tenant, "/")
-	redirectURL := GetCompleteRedirectURL(frt.tenant, redirectURLTemplate)
-	// cookieName, _ := GetCookieName(frt.tenant)
+	authorizeURL := fmt.Sprintf(authorizeURLTemplate, c.frt.tenant, "/")
+	accessTokenURL := rootAccessTokenURL(c.frt.tenant)
+	redirectURL := GetCompleteRedirectURL(c.frt.tenant, redirectURLTemplate)
+	// cookieName, _ := GetCookieName(c.frt.tenant)
 
-	err := frt.GetAuthCode(authorizeURL, redirectURL, codeChallenge, codeChallengeMethod)
+	err := c.GetAuthCodeContext(ctx, authorizeURL, redirectURL, codeChallenge, codeChallengeMethod)
 	if err != nil {
 		return errors.New(fmt.Sprintf("ERROR: error getting access token"))
 	}
 
-	client := resty.New()
-	// client.SetRedirectPolicy(AuthCodeExtractRedirectPolicy())
-	// client.SetDebug(true)
 	var resp1 *resty.Response
 	var err1 error
-	if frt.deploymentType == "Cloud" {
-		client.SetBasicAuth(adminClientId, adminClientPassword)
-		resp1, err1 = client.R().
+	if c.frt.deploymentType == "Cloud" {
+		resp1, err1 = c.request().
+			SetContext(ctx).
+			SetBasicAuth(adminClientId, adminClientPassword).
 			SetHeader("Content-Type", "application/x-www-form-urlencoded").
 			SetFormData(map[string]string{
 				"redirect_uri":  redirectURL,
@@ -217,7 +288,8 @@ func (frt *FRToken) GetAccessToken() error {
 			}).
 			Post(accessTokenURL)
 	} else {
-		resp1, err1 = client.R().
+		resp1, err1 = c.request().
+			SetContext(ctx).
 			SetHeader("Content-Type", "application/x-www-form-urlencoded").
 			SetFormData(map[string]string{
 				"client_id":     adminClientId,
@@ -231,12 +303,13 @@ func (frt *FRToken) GetAccessToken() error {
 
 	if err1 == nil {
 		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
-			return errors.New(fmt.Sprintf("ERROR: access token call returned %d\n", resp1.StatusCode()))
+			return newAPIError(resp1, fmt.Sprintf("ERROR: access token call returned %d\n", resp1.StatusCode()))
 		}
-		accessToken, err2 := ExtractTokenFromResponse(resp1.Body(), "access_token")
+		err2 := c.setBearer(resp1.Body())
 		if err2 == nil {
-			// log.Printf("access token: %s", accessToken)
-			frt.bearerToken = accessToken
+			c.frt.renewBearer = func(ctx context.Context, c *Client) error {
+				return c.GetAccessTokenContext(ctx)
+			}
 			return nil
 		} else {
 			return errors.New(fmt.Sprintf("ERROR: can not extract access token from response, %s\n", err2.Error()))
@@ -246,25 +319,63 @@ func (frt *FRToken) GetAccessToken() error {
 	}
 }
 
-func (frt *FRToken) GetAuthCode(authorizeURL string, redirectURL string, codeChallenge string, codeChallengeMethod string) error {
-	client := resty.New()
-	client.SetRedirectPolicy(AuthCodeExtractRedirectPolicy())
-	// client.SetDebug(true)
+func (c *Client) getClientCredentialsToken(ctx context.Context) error {
+	accessTokenURL := rootAccessTokenURL(c.frt.tenant)
+	resp1, err1 := c.request().
+		SetContext(ctx).
+		SetBasicAuth(c.frt.clientId, c.frt.clientSecret).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetFormData(map[string]string{
+			"grant_type": "client_credentials",
+			"scope":      c.frt.scope,
+		}).
+		Post(accessTokenURL)
+	if err1 != nil {
+		return errors.New(fmt.Sprintf("ERROR: client credentials access token call failed, %s\n", err1.Error()))
+	}
+	if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+		return newAPIError(resp1, fmt.Sprintf("ERROR: client credentials access token call returned %d\nlikely cause: wrong client id or secret, or scope not allowed for the client", resp1.StatusCode()))
+	}
+	err2 := c.setBearer(resp1.Body())
+	if err2 != nil {
+		return errors.New(fmt.Sprintf("ERROR: can not extract access token from response, %s\n", err2.Error()))
+	}
+	c.frt.renewBearer = func(ctx context.Context, c *Client) error {
+		return c.getClientCredentialsToken(ctx)
+	}
+	return nil
+}
+
+func (frt *FRToken) GetAccessToken() error {
+	return defaultClient(frt).GetAccessToken()
+}
+
+func (frt *FRToken) GetAccessTokenContext(ctx context.Context) error {
+	return defaultClient(frt).GetAccessTokenContext(ctx)
+}
+
+func (c *Client) GetAuthCode(authorizeURL string, redirectURL string, codeChallenge string, codeChallengeMethod string) error {
+	return c.GetAuthCodeContext(context.Background(), authorizeURL, redirectURL, codeChallenge, codeChallengeMethod)
+}
+
+func (c *Client) GetAuthCodeContext(ctx context.Context, authorizeURL string, redirectURL string, codeChallenge string, codeChallengeMethod string) error {
+	client := c.redirectClient(AuthCodeExtractRedirectPolicy())
 	resp1, err1 := client.R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/x-www-form-urlencoded").
 		SetFormData(map[string]string{
 			"redirect_uri":          redirectURL,
 			"scope":                 idmAdminScope,
 			"response_type":         "code",
 			"client_id":             adminClientId,
-			"csrf":                  frt.tokenId,
+			"csrf":                  c.frt.tokenId,
 			"decision":              "allow",
 			"code_challenge":        codeChallenge,
 			"code_challenge_method": codeChallengeMethod,
 		}).
 		SetCookie(&http.Cookie{
-			Name:  frt.cookieName,
-			Value: frt.tokenId,
+			Name:  c.frt.cookieName,
+			Value: c.frt.tokenId,
 		}).
 		Post(authorizeURL)
 
@@ -272,7 +383,7 @@ func (frt *FRToken) GetAuthCode(authorizeURL string, redirectURL string, codeCha
 
 	if err1 == nil {
 		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
-			return errors.New(fmt.Sprintf("ERROR: authorize call returned %d\nlikely cause: mismatched parameters with OAuth client config", resp1.StatusCode()))
+			return newAPIError(resp1, fmt.Sprintf("ERROR: authorize call returned %d\nlikely cause: mismatched parameters with OAuth client config", resp1.StatusCode()))
 		}
 		return nil
 	} else {
@@ -280,6 +391,14 @@ func (frt *FRToken) GetAuthCode(authorizeURL string, redirectURL string, codeCha
 	}
 }
 
+func (frt *FRToken) GetAuthCode(authorizeURL string, redirectURL string, codeChallenge string, codeChallengeMethod string) error {
+	return defaultClient(frt).GetAuthCode(authorizeURL, redirectURL, codeChallenge, codeChallengeMethod)
+}
+
+func (frt *FRToken) GetAuthCodeContext(ctx context.Context, authorizeURL string, redirectURL string, codeChallenge string, codeChallengeMethod string) error {
+	return defaultClient(frt).GetAuthCodeContext(ctx, authorizeURL, redirectURL, codeChallenge, codeChallengeMethod)
+}
+
 func CheckAndSkip2FA(payload []byte) (string, error) {
 	jsonMap := make(map[string](interface{}))
 	err := json.Unmarshal([]byte(payload), &jsonMap)
@@ -312,69 +431,139 @@ func CheckAndSkip2FA(payload []byte) (string, error) {
 	}
 }
 
-func (frt *FRToken) Authenticate(username string, password string) error {
+func (c *Client) Authenticate(username string, password string) error {
+	return c.AuthenticateContext(context.Background(), username, password)
+}
+
+func (c *Client) AuthenticateContext(ctx context.Context, username string, password string) error {
+	return c.AuthenticateWithHandlersContext(ctx, username, password, SkipMFAHandler{})
+}
+
+// AuthenticateWithHandlers logs in like Authenticate and answers every page of callbacks
+// AM returns after the username and password with handlers, e.g. a TOTPHandler for
+// admins enrolled in MFA, until AM hands out a session. A page with a callback none of the
+// handlers answers fails the login right away.
+func (c *Client) AuthenticateWithHandlers(username string, password string, handlers ...CallbackHandler) error {
+	return c.AuthenticateWithHandlersContext(context.Background(), username, password, handlers...)
+}
+
+func (c *Client) AuthenticateWithHandlersContext(ctx context.Context, username string, password string, handlers ...CallbackHandler) error {
 
-	frt.GetCookieName()
+	c.GetCookieNameContext(ctx)
 
-	client := resty.New()
-	// client.SetDebug(true)
+	tokenId, err := c.login(ctx, username, password, handlers)
+	if err != nil {
+		return err
+	}
+	c.startSession(ctx, tokenId)
+	c.frt.renewSession = nil
+	if c.frt.credentialRenewal {
+		c.frt.renewSession = func(ctx context.Context, c *Client) error {
+			tokenId, err := c.login(ctx, username, password, handlers)
+			if err != nil {
+				return err
+			}
+			c.startSession(ctx, tokenId)
+			return nil
+		}
+	}
+	c.GetVersionInfoContext(ctx)
+	c.DetermineDeploymentContext(ctx)
+	// fmt.Printf("%s, %s, %s, %s, %s, %s\n", c.frt.tenant, c.frt.realm, c.frt.cookieName, c.frt.tokenId, c.frt.bearerToken, c.frt.version)
+	return nil
+}
+
+// startSession records tokenId and, when AM tells, when the session expires.
+func (c *Client) startSession(ctx context.Context, tokenId string) {
+	c.setSession(tokenId, time.Time{})
+	expiry, err := c.sessionExpiry(ctx, "getSessionInfo")
+	if err == nil {
+		c.setSession(tokenId, expiry)
+	}
+}
+
+// pages of callbacks a login may take before giving up
+const maxAuthSteps int = 20
+
+// login sends the admin credentials and answers the callback pages that follow with
+// handlers, returning the session token.
+func (c *Client) login(ctx context.Context, username string, password string, handlers []CallbackHandler) (string, error) {
 	// realm for authentication is always "/"
-	authURL := fmt.Sprintf("%s/json%s/authenticate", frt.tenant, GetRealmUrl("/"))
+	authURL := fmt.Sprintf(authenticateURLTemplate, c.frt.tenant, GetRealmUrl("/"))
 	// fmt.Printf("%s\n", authURL)
-	resp1, err1 := client.R().
+	resp1, err1 := c.request().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetHeader("Accept-API-Version", apiVersion).
 		SetHeader("X-OpenAM-Username", username).
 		SetHeader("X-OpenAM-Password", password).
 		SetBody("{}").
 		Post(authURL)
+	if err1 != nil {
+		return "", errors.New(fmt.Sprintf("ERROR: first authenticate call failed, %s\n", err1.Error()))
+	}
+	if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
+		return "", newAPIError(resp1, fmt.Sprintf("ERROR: first authenticate call returned %d\nlikely cause: wrong username or password", resp1.StatusCode()))
+	}
 
-	if err1 == nil {
-		if resp1.StatusCode() < 200 || resp1.StatusCode() > 399 {
-			return errors.New(fmt.Sprintf("ERROR: first authenticate call returned %d\nlikely cause: wrong username or password", resp1.StatusCode()))
+	for step := 1; ; step++ {
+		tokenId, err := ExtractTokenFromResponse(resp1.Body(), "tokenId")
+		if err == nil {
+			return tokenId, nil
 		}
-		newPayload, err2 := CheckAndSkip2FA(resp1.Body())
-		if err2 == nil {
-			resp2, err3 := client.R().
-				SetHeader("Content-Type", "application/json").
-				SetHeader("Accept-API-Version", apiVersion).
-				SetBody(newPayload).
-				Post(authURL)
-			if resp2.StatusCode() < 200 || resp2.StatusCode() > 399 {
-				return errors.New(fmt.Sprintf("ERROR: skip 2FA call returned %d\nlikely cause: 2FA skipping not possible, or skip callback changed", resp2.StatusCode()))
-			}
-			if err3 == nil {
-				// log.Printf("cookies: %s", resp2.Cookies())
-				tokenId, err4 := ExtractTokenFromResponse(resp2.Body(), "tokenId")
-				if err4 == nil {
-					frt.tokenId = tokenId
-					frt.GetVersionInfo()
-					frt.DetermineDeployment()
-					// fmt.Printf("%s, %s, %s, %s, %s, %s\n", frt.tenant, frt.realm, frt.cookieName, frt.tokenId, frt.bearerToken, frt.version)
-					return nil
-				} else {
-					return errors.New(fmt.Sprintf("ERROR: can not extract tokenId from response, %s\n", err4.Error()))
-				}
-			} else {
-				return errors.New(fmt.Sprintf("ERROR: second authenticate call failed, %s\n", err3.Error()))
-			}
-		} else {
-			if err2.Error() == "NO2FA" {
-				// 2FA is not needed - most likely non-cloud deployment
-				tokenId, err4 := ExtractTokenFromResponse([]byte(newPayload), "tokenId")
-				if err4 == nil {
-					frt.tokenId = tokenId
-					frt.GetVersionInfo()
-					frt.DetermineDeployment()
-					return nil
-				} else {
-					return errors.New(fmt.Sprintf("ERROR: can not extract tokenId from response, %s\n", err4.Error()))
-				}
-			} else {
-				return errors.New(fmt.Sprintf("ERROR: error checking for 2FA, %s\n", err2.Error()))
-			}
+		var authStep AuthStep
+		err = json.Unmarshal(resp1.Body(), &authStep)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("ERROR: fail to unmarshal authenticate response json, %s", err.Error()))
 		}
-	} else {
-		return errors.New(fmt.Sprintf("ERROR: first authenticate call failed, %s\n", err1.Error()))
+		if len(authStep.Callbacks) == 0 {
+			return "", errors.New("ERROR: authenticate response has neither tokenId nor callbacks")
+		}
+		if step > maxAuthSteps {
+			return "", errors.New(fmt.Sprintf("ERROR: no tokenId after %d authenticate calls\nlikely cause: a callback no handler can answer", maxAuthSteps))
+		}
+		unanswered, err := handleCallbacks(&authStep, handlers)
+		if err != nil {
+			return "", err
+		}
+		if len(unanswered) > 0 {
+			// don't post the page again, each try may send another OTP or count as a failed login
+			return "", errors.New(fmt.Sprintf("ERROR: no handler answers the %s on the login page\nlikely cause: the admin has to do MFA, pass a handler for it to AuthenticateWithHandlers", strings.Join(unanswered, ", ")))
+		}
+		payload, err := json.Marshal(authStep)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("ERROR: fail to marshal json, %s\n", err.Error()))
+		}
+		// carry over cookies like amlbcookie from the previous call
+		resp2, err2 := c.request().
+			SetContext(ctx).
+			SetCookies(resp1.Cookies()).
+			SetHeader("Content-Type", "application/json").
+			SetHeader("Accept-API-Version", apiVersion).
+			SetBody(payload).
+			Post(authURL)
+		if err2 != nil {
+			return "", errors.New(fmt.Sprintf("ERROR: authenticate call failed, %s\n", err2.Error()))
+		}
+		if resp2.StatusCode() < 200 || resp2.StatusCode() > 399 {
+			return "", newAPIError(resp2, fmt.Sprintf("ERROR: authenticate call returned %d\nlikely cause: wrong answer to a callback, or 2FA skipping not possible", resp2.StatusCode()))
+		}
+		resp1 = resp2
 	}
 }
+
+func (frt *FRToken) Authenticate(username string, password string) error {
+	return defaultClient(frt).Authenticate(username, password)
+}
+
+func (frt *FRToken) AuthenticateContext(ctx context.Context, username string, password string) error {
+	return defaultClient(frt).AuthenticateContext(ctx, username, password)
+}
+
+func (frt *FRToken) AuthenticateWithHandlers(username string, password string, handlers ...CallbackHandler) error {
+	return defaultClient(frt).AuthenticateWithHandlers(username, password, handlers...)
+}
+
+func (frt *FRToken) AuthenticateWithHandlersContext(ctx context.Context, username string, password string, handlers ...CallbackHandler) error {
+	return defaultClient(frt).AuthenticateWithHandlersContext(ctx, username, password, handlers...)
+}
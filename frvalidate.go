@@ -0,0 +1,226 @@
+package frodolibs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+type Severity string
+
+const (
+	// the journey can't be imported or will break when it runs
+	SeverityError Severity = "error"
+	// the journey works but likely not as intended
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is one problem ValidateJourney found. NodeId is empty for problems of the
+// tree itself.
+type Finding struct {
+	Severity Severity
+	Tree     string
+	NodeId   string
+	Message  string
+}
+
+func (f Finding) String() string {
+	if f.NodeId == "" {
+		return fmt.Sprintf("%s: %s: %s", f.Severity, f.Tree, f.Message)
+	}
+	return fmt.Sprintf("%s: %s node %s: %s", f.Severity, f.Tree, f.NodeId, f.Message)
+}
+
+// outcomes set by scripted decision node scripts, `outcome = "x"` or `action.goTo("x")`
+var scriptOutcomePattern = regexp.MustCompile(`(?:\boutcome\s*=\s*|\bgoTo\(\s*)["']([^"']+)["']`)
+
+// ValidateJourney checks a GetJourneyData export for broken references and wiring before
+// it is imported. Inner trees of a deep export are checked as well.
+func ValidateJourney(journeyMap map[string]interface{}) ([]Finding, error) {
+	journey, err := JourneyFromMap(journeyMap)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateJourneyTyped(journey), nil
+}
+
+func ValidateJourneyTyped(journey Journey) []Finding {
+	v := &journeyValidator{journey: journey, tree: journey.Tree.Id}
+	v.validate()
+	findings := v.findings
+	innerTrees := make([]string, 0, len(journey.InnerTrees))
+	for name := range journey.InnerTrees {
+		innerTrees = append(innerTrees, name)
+	}
+	sort.Strings(innerTrees)
+	for _, name := range innerTrees {
+		findings = append(findings, ValidateJourneyTyped(journey.InnerTrees[name])...)
+	}
+	return findings
+}
+
+type journeyValidator struct {
+	journey  Journey
+	tree     string
+	findings []Finding
+}
+
+func (v *journeyValidator) add(severity Severity, nodeId string, format string, args ...interface{}) {
+	v.findings = append(v.findings, Finding{Severity: severity, Tree: v.tree, NodeId: nodeId, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *journeyValidator) validate() {
+	tree := v.journey.Tree
+	nodeIds := make([]string, 0, len(tree.Nodes))
+	for nodeId := range tree.Nodes {
+		nodeIds = append(nodeIds, nodeId)
+	}
+	sort.Strings(nodeIds)
+
+	if _, exists := tree.Nodes[tree.EntryNodeId]; !exists {
+		v.add(SeverityError, "", "entry node %s is not a node of the tree", tree.EntryNodeId)
+	}
+	for _, nodeId := range nodeIds {
+		nodeRef := tree.Nodes[nodeId]
+		outcomes := make([]string, 0, len(nodeRef.Connections))
+		for outcome := range nodeRef.Connections {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Strings(outcomes)
+		for _, outcome := range outcomes {
+			target := nodeRef.Connections[outcome]
+			if _, exists := tree.Nodes[target]; !exists && target != SuccessNodeId && target != FailureNodeId {
+				v.add(SeverityError, nodeId, "outcome %s connects to %s, which is not a node of the tree", outcome, target)
+			}
+		}
+		node, exists := v.journey.Nodes[nodeId]
+		if !exists {
+			v.add(SeverityError, nodeId, "%s has no configuration in nodes", nodeRef.NodeType)
+			continue
+		}
+		v.validateNode(nodeId, node, nodeRef.Connections)
+		if containerNodes[nodeRef.NodeType] {
+			v.validatePage(nodeId, node)
+		}
+	}
+	v.validateReachable(nodeIds)
+}
+
+func (v *journeyValidator) validateReachable(nodeIds []string) {
+	tree := v.journey.Tree
+	reached := make(map[string]bool)
+	pending := []string{tree.EntryNodeId}
+	for len(pending) > 0 {
+		nodeId := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reached[nodeId] {
+			continue
+		}
+		reached[nodeId] = true
+		for _, target := range tree.Nodes[nodeId].Connections {
+			pending = append(pending, target)
+		}
+	}
+	for _, nodeId := range nodeIds {
+		if !reached[nodeId] {
+			v.add(SeverityWarning, nodeId, "%s can't be reached from the entry node", tree.Nodes[nodeId].NodeType)
+		}
+	}
+}
+
+func (v *journeyValidator) validatePage(nodeId string, node Node) {
+	pageNode, err := node.AsPageNode()
+	if err != nil {
+		v.add(SeverityError, nodeId, "%s", err.Error())
+		return
+	}
+	for _, child := range pageNode.Nodes {
+		innerNode, exists := v.journey.InnerNodes[child.Id]
+		if !exists {
+			v.add(SeverityError, child.Id, "%s on page %s has no configuration in innernodes", child.NodeType, nodeId)
+			continue
+		}
+		v.validateNode(child.Id, innerNode, nil)
+	}
+}
+
+// validateNode checks the scripts and email templates a node refers to, connections is
+// nil for nodes on a page.
+func (v *journeyValidator) validateNode(nodeId string, node Node, connections map[string]string) {
+	nodeType := node.Type.Id
+	if templateId, exists := node.StringProperty("emailTemplateName"); emailTemplateNodes[nodeType] && exists {
+		if _, exists := v.journey.EmailTemplates[templateId]; !exists && len(v.journey.EmailTemplates) == 0 {
+			// exports of Classic deployments never include email templates
+			v.add(SeverityWarning, nodeId, "email template %s is not part of the export, it has to exist in the target", templateId)
+		} else if !exists {
+			v.add(SeverityError, nodeId, "email template %s is missing from emailTemplates", templateId)
+		}
+	}
+	if !scriptedNodes[nodeType] {
+		return
+	}
+	scriptId, exists := node.StringProperty("script")
+	if !exists {
+		v.add(SeverityError, nodeId, "%s has no script", nodeType)
+		return
+	}
+	script, exists := v.journey.Scripts[scriptId]
+	if !exists {
+		v.add(SeverityError, nodeId, "script %s is missing from scripts", scriptId)
+		return
+	}
+	if nodeType != "ScriptedDecisionNode" || connections == nil {
+		return
+	}
+
+	var declared []string
+	if raw, exists := node.Extra["outcomes"]; exists {
+		err := json.Unmarshal(raw, &declared)
+		if err != nil {
+			v.add(SeverityError, nodeId, "outcomes is not a list of strings, %s", err.Error())
+			return
+		}
+	}
+	declaredSet := make(map[string]bool)
+	for _, outcome := range declared {
+		declaredSet[outcome] = true
+		if _, exists := connections[outcome]; !exists {
+			v.add(SeverityError, nodeId, "outcome %s is not connected", outcome)
+		}
+	}
+	connected := make([]string, 0, len(connections))
+	for outcome := range connections {
+		connected = append(connected, outcome)
+	}
+	sort.Strings(connected)
+	for _, outcome := range connected {
+		if !declaredSet[outcome] {
+			v.add(SeverityError, nodeId, "connection %s is not an outcome the node declares", outcome)
+		}
+	}
+
+	source, err := script.Source()
+	if err != nil {
+		v.add(SeverityWarning, nodeId, "%s", err.Error())
+		return
+	}
+	seen := make(map[string]bool)
+	for _, match := range scriptOutcomePattern.FindAllStringSubmatch(source, -1) {
+		outcome := match[1]
+		if !declaredSet[outcome] && !seen[outcome] {
+			seen[outcome] = true
+			v.add(SeverityWarning, nodeId, "script %s sets outcome %s, which the node doesn't declare", script.Name, outcome)
+		}
+	}
+}
+
+// HasErrors tells whether any of findings is an error.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
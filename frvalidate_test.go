@@ -0,0 +1,109 @@
+package frodolibs
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// validateFixture builds an export around a ScriptedDecisionNode "check" using script s1
+// with source, extra tree nodes, node configs and top level fields can be added.
+func validateFixture(source string, treeNodes string, nodes string, extra string) string {
+	return `{
+		"tree": {"_id": "Login", "entryNodeId": "check", "nodes": {
+			"check": {"nodeType": "ScriptedDecisionNode", "connections": {
+				"true": "70e691a5-1e33-4ac3-a356-e7b6d60d92e0", "false": "e301438c-0bd0-429c-ab0c-66126501069a"}}` + treeNodes + `}},
+		"nodes": {
+			"check": {"_id": "check", "_type": {"_id": "ScriptedDecisionNode"}, "script": "s1", "outcomes": ["true", "false"]}` + nodes + `},
+		"scripts": {"s1": {"_id": "s1", "name": "checker", "script": "` + base64.StdEncoding.EncodeToString([]byte(source)) + `"}}` + extra + `
+	}`
+}
+
+func findingStrings(findings []Finding) []string {
+	found := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		found = append(found, finding.String())
+	}
+	sort.Strings(found)
+	return found
+}
+
+func TestValidateJourney(t *testing.T) {
+	const okSource = `if (x) { outcome = "true"; } else { outcome = "false"; }`
+	tests := []struct {
+		name    string
+		journey string
+		want    []string
+	}{
+		{"valid", validateFixture(okSource, "", "", `, "emailTemplates": {}`), nil},
+		{"missing entry node", strings.Replace(validateFixture(okSource, "", "", ""), `"entryNodeId": "check"`, `"entryNodeId": "gone"`, 1), []string{
+			"error: Login: entry node gone is not a node of the tree",
+			"warning: Login node check: ScriptedDecisionNode can't be reached from the entry node",
+		}},
+		{"dangling connection and unreachable node", validateFixture(okSource,
+			`, "lost": {"nodeType": "MessageNode", "connections": {"true": "nowhere"}}`,
+			`, "lost": {"_id": "lost", "_type": {"_id": "MessageNode"}}`, ""), []string{
+			"error: Login node lost: outcome true connects to nowhere, which is not a node of the tree",
+			"warning: Login node lost: MessageNode can't be reached from the entry node",
+		}},
+		{"page child missing from innernodes", strings.Replace(validateFixture(okSource,
+			`, "page": {"nodeType": "PageNode", "connections": {"outcome": "check"}}`,
+			`, "page": {"_id": "page", "_type": {"_id": "PageNode"}, "nodes": [{"_id": "user", "nodeType": "ValidatedUsernameNode"}]}`,
+			`, "innernodes": {}`), `"entryNodeId": "check"`, `"entryNodeId": "page"`, 1), []string{
+			"error: Login node user: ValidatedUsernameNode on page page has no configuration in innernodes",
+		}},
+		{"script missing", strings.Replace(validateFixture(okSource, "", "", ""), `"script": "s1"`, `"script": "s2"`, 1), []string{
+			"error: Login node check: script s2 is missing from scripts",
+		}},
+		// outcomes the node declares but doesn't connect, connections it doesn't declare and
+		// outcomes only the script sets
+		{"outcomes don't match the connections and script", strings.Replace(
+			validateFixture(`outcome = "maybe"; action.goTo("true");`, "", "", ""),
+			`"outcomes": ["true", "false"]`, `"outcomes": ["true", "retry"]`, 1), []string{
+			"error: Login node check: connection false is not an outcome the node declares",
+			"error: Login node check: outcome retry is not connected",
+			"warning: Login node check: script checker sets outcome maybe, which the node doesn't declare",
+		}},
+		{"email template missing from a cloud export", validateFixture(okSource,
+			`, "mail": {"nodeType": "EmailSuspendNode", "connections": {}}`,
+			`, "mail": {"_id": "mail", "_type": {"_id": "EmailSuspendNode"}, "emailTemplateName": "welcome"}`,
+			`, "emailTemplates": {"other": {"_id": "emailTemplate/other"}}`), []string{
+			"error: Login node mail: email template welcome is missing from emailTemplates",
+			"warning: Login node mail: EmailSuspendNode can't be reached from the entry node",
+		}},
+		{"email template in an export without templates", validateFixture(okSource,
+			`, "mail": {"nodeType": "EmailSuspendNode", "connections": {}}`,
+			`, "mail": {"_id": "mail", "_type": {"_id": "EmailSuspendNode"}, "emailTemplateName": "welcome"}`,
+			`, "emailTemplates": {}`), []string{
+			"warning: Login node mail: EmailSuspendNode can't be reached from the entry node",
+			"warning: Login node mail: email template welcome is not part of the export, it has to exist in the target",
+		}},
+		{"inner trees are validated", validateFixture(okSource, "", "", `, "innerTrees": {"MFA": {
+			"tree": {"_id": "MFA", "entryNodeId": "missing", "nodes": {}}, "nodes": {}}}`), []string{
+			"error: MFA: entry node missing is not a node of the tree",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings, err := ValidateJourney(journeyFixture(t, test.journey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := findingStrings(findings)
+			want := append([]string{}, test.want...)
+			sort.Strings(want)
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("findings\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+			wantErrors := false
+			for _, finding := range want {
+				wantErrors = wantErrors || strings.HasPrefix(finding, "error")
+			}
+			if HasErrors(findings) != wantErrors {
+				t.Errorf("HasErrors = %v, want %v", HasErrors(findings), wantErrors)
+			}
+		})
+	}
+}
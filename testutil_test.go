@@ -0,0 +1,59 @@
+package frodolibs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func decodeBody(t *testing.T, r *http.Request, v interface{}) {
+	t.Helper()
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		t.Fatalf("fail to decode request body, %v", err)
+	}
+}
+
+// recordingServer records requests as "METHOD path". A GET is answered from objects by
+// path, or with 404 when it isn't there, everything else with an empty object.
+type recordingServer struct {
+	*httptest.Server
+	objects  map[string]string
+	mu       sync.Mutex
+	requests []string
+}
+
+func newRecordingServer() *recordingServer {
+	rs := &recordingServer{}
+	rs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs.mu.Lock()
+		rs.requests = append(rs.requests, r.Method+" "+r.URL.Path)
+		rs.mu.Unlock()
+		if r.Method != http.MethodGet || rs.objects == nil {
+			w.Write([]byte(`{}`))
+			return
+		}
+		object, exists := rs.objects[r.URL.Path]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code": 404, "reason": "Not Found", "message": "Not Found"}`))
+			return
+		}
+		w.Write([]byte(object))
+	}))
+	return rs
+}
+
+func (rs *recordingServer) recorded() []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return append([]string(nil), rs.requests...)
+}
+
+func (rs *recordingServer) reset() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.requests = nil
+}
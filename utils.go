@@ -1,6 +1,7 @@
 package frodolibs
 
 import (
+	"crypto/rand"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -45,6 +46,18 @@ func GetCompleteRedirectURL(tenant string, uri string) string {
 	return url
 }
 
+func GenerateUUID() (string, error) {
+	u := make([]byte, 16)
+	_, err := rand.Read(u)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("ERROR: fail to generate uuid, %s", err.Error()))
+	}
+	// version 4, variant 10
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]), nil
+}
+
 func ExtractTokenFromResponse(payload []byte, tokenName string) (string, error) {
 	jsonMap := make(map[string](interface{}))
 	err := json.Unmarshal([]byte(payload), &jsonMap)